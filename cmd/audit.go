@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/amenocal/gh-pin-actions/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Reports SHA-pinned actions whose version tag has moved since they were pinned",
+		Long: `Reads every workflow file in .github/workflows and, for each action pinned to a sha
+		with a version comment such as # v4.1.1, resolves the tag again. Tags that now point to a
+		different commit, or no longer exist, are reported and the command exits with a non-zero status.
+		With --transitive, the actions each action uses are walked instead and every reference in
+		the dependency tree that isn't pinned to a sha is reported`,
+		Run: auditWorkflows,
+	}
+	transitive bool
+	maxDepth   int
+)
+
+// commitSHARegexp matches a full commit SHA.
+var commitSHARegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// defaultMaxDepth is how many levels of nested actions --transitive follows unless --max-depth is set.
+const defaultMaxDepth = 5
+
+// auditFinding is a problem the audit found with a pinned reference.
+type auditFinding struct {
+	file    string
+	ref     pkg.PinnedRef
+	message string
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().BoolVar(&transitive, "transitive", false, "walk the actions used by each action at its pinned commit and report nested actions that aren't pinned to a sha")
+	auditCmd.Flags().IntVar(&maxDepth, "max-depth", defaultMaxDepth, "levels of nested actions followed with --transitive")
+}
+
+func auditWorkflows(_ *cobra.Command, _ []string) {
+	initLogger()
+	// A moved tag is exactly what a cached resolution would hide, but the files --transitive
+	// reads at a fixed commit never change.
+	cacheCommitsOnly = true
+	initResolution()
+
+	if maxDepth < 0 {
+		logger.Fatal("Invalid --max-depth", logger.Args("value", maxDepth, "allowed", "0 or more"))
+	}
+
+	workflowFiles, err := getPinnableFiles()
+	if err != nil {
+		logger.Fatal("Error reading .github/workflow files", logger.Args("error:", err))
+	}
+	if transitive {
+		reportFindings(auditTransitive(os.Stdout, workflowFiles, maxDepth), "action reference(s) in the dependency tree not pinned to a sha", "Every action in the dependency tree is pinned")
+		return
+	}
+	reportFindings(auditMovedTags(workflowFiles), "pinned tag(s) that moved since they were pinned", "No moved tags found")
+}
+
+// reportFindings prints each finding as file:line, followed by a summary, and exits with a
+// non-zero status when there are any so CI fails.
+func reportFindings(findings []auditFinding, problem string, clean string) {
+	for _, finding := range findings {
+		fmt.Printf("%s:%d: %s: %s\n", finding.file, finding.ref.Line, finding.ref.Action, finding.message)
+	}
+	if len(findings) > 0 {
+		fmt.Printf("Found %d %s\n", len(findings), problem)
+		os.Exit(1)
+	}
+	fmt.Println(clean)
+}
+
+// readPinnedRefs returns the SHA-pinned uses: references of a workflow or action file. audit
+// and verify both read pins this way, from the uses: values of the YAML tree.
+func readPinnedRefs(file string) ([]pkg.PinnedRef, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.FindPinnedRefs(content)
+}
+
+// auditMovedTags resolves the version tag in the comment of every SHA pin in files again and
+// reports the pins whose tag now points elsewhere. Only full versions such as v4.1.1 are
+// checked, since floating tags like v4 and branches move by design. Each tag is resolved once.
+func auditMovedTags(files []string) []auditFinding {
+	type resolved struct {
+		sha string
+		err error
+	}
+	tags := map[string]resolved{}
+	var findings []auditFinding
+	for _, file := range files {
+		refs, err := readPinnedRefs(file)
+		if err != nil {
+			logger.Warn("Error reading workflow; skipping", logger.Args("file:", file, "error:", err))
+			continue
+		}
+		for _, ref := range refs {
+			if !pkg.IsExactVersion(ref.Comment) {
+				logger.Debug("Pinned action has no version comment to audit", logger.Args("file:", file, "line", ref.Line, "action:", ref.Action))
+				continue
+			}
+			repository := pkg.ExtractOwnerRepo(ref.Repository)
+			key := repository + "@" + ref.Comment
+			r, ok := tags[key]
+			if !ok {
+				r.sha, r.err = resolver.ResolveTag(repository, ref.Comment)
+				tags[key] = r
+			}
+			switch {
+			case errors.Is(r.err, pkg.ErrNotFound):
+				findings = append(findings, auditFinding{file: file, ref: ref, message: fmt.Sprintf("tag %s no longer exists", ref.Comment)})
+			case r.err != nil:
+				logger.Warn("Unable to resolve pinned tag; skipping", logger.Args("file:", file, "line", ref.Line, "action:", ref.Action, "reason:", describeResolveError(r.err)))
+			case r.sha != ref.SHA:
+				findings = append(findings, auditFinding{file: file, ref: ref, message: fmt.Sprintf("tag %s now points to %s", ref.Comment, r.sha)})
+			}
+		}
+	}
+	return findings
+}
+
+// dependencyNode is an action reference in the transitive dependency tree of a workflow.
+type dependencyNode struct {
+	// file is where the reference is made: a local file, or owner/repo/path@sha for the
+	// metadata of a nested action.
+	file string
+	ref  pkg.PinnedRef
+	// problem is why the reference is reported, empty when it is pinned.
+	problem string
+	// note says why the action's own dependencies weren't walked, such as a cycle.
+	note     string
+	children []*dependencyNode
+}
+
+// actionMetadata is the action.yml (or reusable workflow) of an action at a commit.
+type actionMetadata struct {
+	path    string
+	content []byte
+	err     error
+}
+
+// dependencyWalker walks nested uses: references through the metadata file of each action at
+// the commit its reference resolves to.
+type dependencyWalker struct {
+	maxDepth int
+	// metadata memoizes the metadata fetched per owner/repo@sha:dir.
+	metadata map[string]actionMetadata
+	// visiting holds the actions on the path being walked, to detect cycles.
+	visiting map[string]bool
+}
+
+// auditTransitive prints the dependency tree of every file in files to w and reports the
+// references anywhere in the trees that aren't pinned to a commit SHA or image digest:
+// version tags, branches, and refs that can't be resolved at all.
+func auditTransitive(w io.Writer, files []string, maxDepth int) []auditFinding {
+	walker := &dependencyWalker{maxDepth: maxDepth, metadata: map[string]actionMetadata{}, visiting: map[string]bool{}}
+	var findings []auditFinding
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			logger.Warn("Error reading workflow; skipping", logger.Args("file:", file, "error:", err))
+			continue
+		}
+		refs, err := pkg.FindUsesRefs(content)
+		if err != nil {
+			logger.Warn("Error reading workflow; skipping", logger.Args("file:", file, "error:", err))
+			continue
+		}
+		var nodes []*dependencyNode
+		for _, ref := range refs {
+			nodes = append(nodes, walker.walk(file, ref, 0))
+		}
+		fmt.Fprintln(w, file)
+		printDependencyTree(w, nodes, "")
+		findings = append(findings, dependencyFindings(nodes)...)
+	}
+	return findings
+}
+
+// walk builds the node for a uses: reference made in file, and below depth maxDepth the
+// nodes of the references made by the action's own metadata.
+func (w *dependencyWalker) walk(file string, uses pkg.UsesRef, depth int) *dependencyNode {
+	node := &dependencyNode{file: file, ref: pkg.PinnedRef{Line: uses.Line, Action: uses.Value}}
+	action := uses.Value
+	switch {
+	case strings.HasPrefix(action, dockerPrefix):
+		if image, err := pkg.ParseImageRef(strings.TrimPrefix(action, dockerPrefix)); err != nil || image.Digest == "" {
+			node.problem = "image not pinned to a digest"
+		}
+		return node
+	case strings.HasPrefix(action, "./"):
+		node.note = "local"
+		return node
+	}
+	repoWithOwner, ref, err := pkg.SplitActionString(action, "@")
+	if err != nil {
+		node.problem = "no ref given"
+		return node
+	}
+	repository := pkg.ExtractOwnerRepo(repoWithOwner)
+	node.ref.Repository = repository
+	node.ref.SHA, node.problem = resolveDependencyRef(repository, ref)
+	if node.ref.SHA == "" {
+		return node
+	}
+	if depth >= w.maxDepth {
+		node.note = "depth limit reached"
+		return node
+	}
+
+	dir := strings.TrimPrefix(strings.TrimPrefix(repoWithOwner, repository), "/")
+	key := repository + "@" + node.ref.SHA + ":" + dir
+	if w.visiting[key] {
+		node.note = "cycle"
+		return node
+	}
+	metadata, ok := w.metadata[key]
+	if !ok {
+		metadata = fetchActionMetadata(repository, dir, node.ref.SHA, reusableWorkflowRegexp.MatchString(action))
+		w.metadata[key] = metadata
+	}
+	if metadata.err != nil {
+		node.note = "metadata unavailable: " + describeResolveError(metadata.err)
+		return node
+	}
+	refs, err := pkg.FindUsesRefs(metadata.content)
+	if err != nil {
+		node.note = "metadata unreadable: " + err.Error()
+		return node
+	}
+	w.visiting[key] = true
+	for _, ref := range refs {
+		node.children = append(node.children, w.walk(fmt.Sprintf("%s/%s@%s", repository, metadata.path, node.ref.SHA), ref, depth+1))
+	}
+	delete(w.visiting, key)
+	return node
+}
+
+// resolveDependencyRef returns the commit ref names in repository and, unless ref is a commit
+// SHA, why the reference is reported. Refs are looked up as a tag first, then as a branch.
+func resolveDependencyRef(repository, ref string) (string, string) {
+	if commitSHARegexp.MatchString(ref) {
+		return ref, ""
+	}
+	if sha, err := resolver.ResolveTag(repository, ref); err == nil {
+		return sha, fmt.Sprintf("not pinned to a sha (tag %s)", ref)
+	}
+	sha, err := resolver.ResolveBranch(repository, ref)
+	switch {
+	case err == nil:
+		return sha, fmt.Sprintf("references branch %s", ref)
+	case !errors.Is(err, pkg.ErrNotFound):
+		return "", fmt.Sprintf("not pinned to a sha, and %s could not be resolved: %s", ref, describeResolveError(err))
+	}
+	return "", fmt.Sprintf("%s is neither a tag nor a branch of %s", ref, repository)
+}
+
+// fetchActionMetadata fetches the file describing an action in dir of repository at sha: the
+// reusable workflow itself, or the action.yml or action.yaml in dir.
+func fetchActionMetadata(repository, dir, sha string, workflow bool) actionMetadata {
+	candidates := []string{path.Join(dir, "action.yml"), path.Join(dir, "action.yaml")}
+	if workflow {
+		candidates = []string{dir}
+	}
+	var err error
+	for _, candidate := range candidates {
+		var content []byte
+		content, err = resolver.FileContent(repository, candidate, sha)
+		if err == nil {
+			return actionMetadata{path: candidate, content: content}
+		}
+		if !errors.Is(err, pkg.ErrNotFound) {
+			break
+		}
+	}
+	return actionMetadata{err: err}
+}
+
+// printDependencyTree writes nodes and their children to w as an indented tree, with the
+// problem and note of each node in brackets and parentheses.
+func printDependencyTree(w io.Writer, nodes []*dependencyNode, indent string) {
+	for i, node := range nodes {
+		branch, childIndent := "├── ", indent+"│   "
+		if i == len(nodes)-1 {
+			branch, childIndent = "└── ", indent+"    "
+		}
+		line := indent + branch + node.ref.Action
+		if node.problem != "" {
+			line += " [" + node.problem + "]"
+		}
+		if node.note != "" {
+			line += " (" + node.note + ")"
+		}
+		fmt.Fprintln(w, line)
+		printDependencyTree(w, node.children, childIndent)
+	}
+}
+
+// dependencyFindings returns a finding for every reported node of the trees, depth first.
+func dependencyFindings(nodes []*dependencyNode) []auditFinding {
+	var findings []auditFinding
+	for _, node := range nodes {
+		if node.problem != "" {
+			findings = append(findings, auditFinding{file: node.file, ref: node.ref, message: node.problem})
+		}
+		findings = append(findings, dependencyFindings(node.children)...)
+	}
+	return findings
+}
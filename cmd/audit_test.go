@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/amenocal/gh-pin-actions/pkg"
+)
+
+func TestAuditMovedTags(t *testing.T) {
+	resolver = newTestResolver()
+	workflow := filepath.Join(t.TempDir(), "ci.yml")
+	content := "jobs:\n" +
+		"  build:\n" +
+		"    steps:\n" +
+		"      - uses: actions/checkout@" + testShaA + " # v4.2.2\n" +
+		"      - uses: actions/checkout@" + testShaA + " # v4.1.7\n" +
+		"      - uses: actions/checkout/sub@" + testShaC + " #v3.6.0\n" +
+		"      - uses: actions/checkout@" + testShaB + " # v4.0.0\n" +
+		"      - uses: actions/checkout@" + testShaD + " # main\n" +
+		"      - uses: actions/checkout@" + testShaD + " # v4\n" +
+		"      - uses: actions/checkout@" + testShaD + "\n" +
+		"      - run: |\n" +
+		"          echo uses: actions/checkout@" + testShaA + " # v4.1.7\n"
+	if err := os.WriteFile(workflow, []byte(content), 0644); err != nil {
+		t.Fatalf("Unexpected error writing workflow: %v", err)
+	}
+
+	findings := auditMovedTags([]string{workflow})
+	want := []struct {
+		line    int
+		message string
+	}{
+		{line: 5, message: "tag v4.1.7 now points to " + testShaB},
+		{line: 7, message: "tag v4.0.0 no longer exists"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("auditMovedTags returned %d findings, want %d: %+v", len(findings), len(want), findings)
+	}
+	for i, w := range want {
+		if findings[i].file != workflow || findings[i].ref.Line != w.line || !strings.Contains(findings[i].message, w.message) {
+			t.Errorf("finding %d = %s:%d %q, want %s:%d %q", i, findings[i].file, findings[i].ref.Line, findings[i].message, workflow, w.line, w.message)
+		}
+	}
+}
+
+func TestAuditTransitive(t *testing.T) {
+	const (
+		shaE = "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+		shaF = "ffffffffffffffffffffffffffffffffffffffff"
+	)
+	r := newTestResolver()
+	r.Tags["org/composite"] = []pkg.Tag{{Name: "v1", SHA: shaE}}
+	r.Branches["some/dep"] = map[string]string{"main": shaF}
+	r.Files = map[string]map[string]string{
+		"org/composite": {
+			shaE + ":action.yml": "runs:\n" +
+				"  using: composite\n" +
+				"  steps:\n" +
+				"    - uses: some/dep@main\n" +
+				"    - uses: actions/checkout@" + testShaA + "\n" +
+				"    - uses: docker://alpine:3.19\n" +
+				"    - uses: ./local\n" +
+				"    - uses: org/composite/nested@" + shaE + "\n",
+			shaE + ":nested/action.yaml": "runs:\n" +
+				"  using: composite\n" +
+				"  steps:\n" +
+				"    - uses: org/composite@" + shaE + "\n",
+			shaE + ":.github/workflows/build.yml": "jobs:\n" +
+				"  build:\n" +
+				"    steps:\n" +
+				"      - uses: other/missing@v9\n",
+		},
+		"actions/checkout": {
+			testShaA + ":action.yml": "runs:\n  using: node20\n  main: index.js\n",
+		},
+	}
+	resolver = r
+
+	workflow := filepath.Join(t.TempDir(), "ci.yml")
+	content := "jobs:\n" +
+		"  build:\n" +
+		"    steps:\n" +
+		"      - uses: org/composite@v1\n" +
+		"      - uses: actions/checkout@" + testShaA + "\n" +
+		"  call:\n" +
+		"    uses: org/composite/.github/workflows/build.yml@" + shaE + "\n"
+	if err := os.WriteFile(workflow, []byte(content), 0644); err != nil {
+		t.Fatalf("Unexpected error writing workflow: %v", err)
+	}
+
+	var out bytes.Buffer
+	findings := auditTransitive(&out, []string{workflow}, defaultMaxDepth)
+	wantTree := workflow + "\n" +
+		"├── org/composite@v1 [not pinned to a sha (tag v1)]\n" +
+		"│   ├── some/dep@main [references branch main] (metadata unavailable: repository or ref not found)\n" +
+		"│   ├── actions/checkout@" + testShaA + "\n" +
+		"│   ├── docker://alpine:3.19 [image not pinned to a digest]\n" +
+		"│   ├── ./local (local)\n" +
+		"│   └── org/composite/nested@" + shaE + "\n" +
+		"│       └── org/composite@" + shaE + " (cycle)\n" +
+		"├── actions/checkout@" + testShaA + "\n" +
+		"└── org/composite/.github/workflows/build.yml@" + shaE + "\n" +
+		"    └── other/missing@v9 [v9 is neither a tag nor a branch of other/missing]\n"
+	if out.String() != wantTree {
+		t.Errorf("dependency tree =\n%s\nwant\n%s", out.String(), wantTree)
+	}
+
+	want := []struct {
+		file    string
+		line    int
+		message string
+	}{
+		{file: workflow, line: 4, message: "not pinned to a sha (tag v1)"},
+		{file: "org/composite/action.yml@" + shaE, line: 4, message: "references branch main"},
+		{file: "org/composite/action.yml@" + shaE, line: 6, message: "image not pinned to a digest"},
+		{file: "org/composite/.github/workflows/build.yml@" + shaE, line: 4, message: "v9 is neither a tag nor a branch of other/missing"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("auditTransitive returned %d findings, want %d: %+v", len(findings), len(want), findings)
+	}
+	for i, w := range want {
+		if findings[i].file != w.file || findings[i].ref.Line != w.line || findings[i].message != w.message {
+			t.Errorf("finding %d = %s:%d %q, want %s:%d %q", i, findings[i].file, findings[i].ref.Line, findings[i].message, w.file, w.line, w.message)
+		}
+	}
+
+	out.Reset()
+	auditTransitive(&out, []string{workflow}, 0)
+	if !strings.Contains(out.String(), "├── org/composite@v1 [not pinned to a sha (tag v1)] (depth limit reached)\n") {
+		t.Errorf("dependency tree with --max-depth 0 = \n%s\nwant the top-level actions only", out.String())
+	}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/amenocal/gh-pin-actions/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the persistent resolution cache",
+		Long: `Resolved tags, branches and releases are cached under the user cache directory
+		so repeated runs don't query the same refs again. Use --cache-ttl to control how long
+		entries stay valid and --no-cache to bypass the cache for a single run`,
+	}
+	cacheClearCmd = &cobra.Command{
+		Use:   "clear",
+		Short: "Removes every cached resolution",
+		Run:   clearCache,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
+
+func clearCache(_ *cobra.Command, _ []string) {
+	initLogger()
+	cacheDir, err := pkg.DefaultCacheDir()
+	if err != nil {
+		logger.Fatal("Unable to locate cache directory", logger.Args("error:", err))
+	}
+	if err := pkg.ClearCache(cacheDir); err != nil {
+		logger.Fatal("Unable to clear cache", logger.Args("dir:", cacheDir, "error:", err))
+	}
+	fmt.Println("Cleared resolution cache:", cacheDir)
+}
@@ -4,15 +4,16 @@ Copyright © 2023 Alejandro Menocal
 package cmd
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/amenocal/gh-pin-actions/pkg"
-	"github.com/cli/go-gh/v2"
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/cli/go-gh/v2/pkg/auth"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -33,11 +34,39 @@ var (
 	version    string
 	debug      bool
 	branchName string
+	resolver   pkg.Resolver
+	backend    string
+	mirrorDir  string
+	noCache    bool
+	cacheTTL   time.Duration
+	maxRetries int
+	hostname   string
+	prerelease string
+	floating   bool
+	minAgeFlag string
+	// minAge is the parsed --min-age flag.
+	minAge   time.Duration
+	asOfFlag string
+	// asOf is the parsed --as-of flag, the zero time when unset.
+	asOf               time.Time
+	requireSigned      bool
+	signedExemptOwners []string
+	// prereleasePolicy is the parsed --prerelease flag.
+	prereleasePolicy = pkg.PrereleaseExclude
+	// cacheCommitsOnly limits the resolution cache to lookups of a fixed commit, for commands
+	// that must see every tag and branch as it is now.
+	cacheCommitsOnly bool
 )
 
-const latestVersion = "latest"
+// floatingTagRegexp matches the major or major.minor versions that --floating resolves
+// through a moving tag of the same name.
+var floatingTagRegexp = regexp.MustCompile(`^\d+(\.\d+)?$`)
 
-var versionFormatRegexp = regexp.MustCompile(`^v?\d+(\.\d+)?(\.\d+)?$`)
+const (
+	latestVersion = "latest"
+	backendREST   = "rest"
+	backendGh     = "gh"
+)
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
@@ -62,40 +91,56 @@ func init() {
 		logger.Fatal("error marking repository flag as required", logger.Args("error:", err))
 	}
 
-	rootCmd.Flags().StringVarP(&version, "version", "v", latestVersion, "version of the tag to pin to (ex. 3; 3.1; 3.1.1)")
+	rootCmd.Flags().StringVarP(&version, "version", "v", latestVersion, "version or version constraint of the tag to pin to (ex. 3; 3.1; 3.1.1; ^4.1; ~3.2.0; '>=2.5 <3'; '4 !=4.0.1')")
 
 	rootCmd.Flags().StringVarP(&branchName, "branch", "b", "", "branch name to pin to")
 
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug mode - set logger to debug level")
+	rootCmd.PersistentFlags().StringVar(&hostname, "hostname", "", "GitHub host to resolve actions against, e.g. a GitHub Enterprise Server (default: GH_HOST or the gh default host)")
+	rootCmd.PersistentFlags().StringVar(&backend, "backend", backendREST, "resolution backend: rest (GitHub REST API) or gh (gh CLI subprocesses)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "bypass the persistent resolution cache")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", pkg.DefaultCacheTTL, "how long cached resolutions stay valid")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", pkg.DefaultMaxRetries, "retries for GitHub API requests rejected by a rate limit")
+	rootCmd.PersistentFlags().StringVar(&prerelease, "prerelease", string(pkg.PrereleaseExclude), "pre-release policy when resolving versions: exclude, include or only")
+	rootCmd.PersistentFlags().BoolVar(&floating, "floating", false, "pin the commit a floating tag such as v4 points at, commenting the exact release tag that shares it")
+	rootCmd.PersistentFlags().StringVar(&minAgeFlag, "min-age", "", "only pin versions published at least this long ago, e.g. 7d, 2w or 36h, falling back to the newest older version")
+	rootCmd.PersistentFlags().StringVar(&asOfFlag, "as-of", "", "resolve as of a point in time, only considering versions published before it (YYYY-MM-DD or RFC 3339)")
+	rootCmd.PersistentFlags().BoolVar(&requireSigned, "require-signed", false, "refuse to pin commits without a verified GPG, SSH or S/MIME signature")
+	rootCmd.PersistentFlags().StringSliceVar(&signedExemptOwners, "signed-exempt-owner", nil, "owner exempt from --require-signed (repeatable or comma-separated)")
+	rootCmd.PersistentFlags().StringVar(&mirrorDir, "mirror-dir", "", "resolve offline from local git mirrors laid out as <dir>/<owner>/<repo>.git (overrides --backend)")
 }
 
 func ActionsPin(_ *cobra.Command, _ []string) {
 	var shaCommit string
 	var tagVersion string
 	var err error
-	if debug {
-		logger = pterm.DefaultLogger.WithLevel(pterm.LogLevelDebug)
-	} else {
-		logger = pterm.DefaultLogger.WithLevel(pterm.LogLevelWarn)
-	}
+	initLogger()
+	initResolution()
 
 	if branchName != "" {
 		fmt.Println("Branch name:", branchName)
 		tagVersion = branchName
 		shaCommit, err = GetBranchHash(repository, branchName)
+		if err == nil {
+			err = CheckSignature(repository, shaCommit, branchName)
+		}
 	} else {
-		isVersionFormat := versionFormatRegexp.MatchString(version)
-		if version == latestVersion || version == "" || isVersionFormat {
+		_, constraintErr := pkg.ParseConstraint(version)
+		if version == latestVersion || version == "" || constraintErr == nil {
 			fmt.Println("Version:", version)
 			shaCommit, tagVersion, err = GetActionHashByVersion(repository, version)
+			if err == nil {
+				err = CheckSignature(repository, shaCommit, tagVersion)
+			}
 		} else {
-			logger.Fatal("version flag must be in the format v1, v1.1, or v1.1.1", logger.Args("version received", version),
+			logger.Fatal("version flag must be a version such as v1, v1.1 or v1.1.1, or a constraint such as ^4.1 or >=2.5 <3", logger.Args("version received", version),
+				logger.Args("reason", constraintErr.Error()),
 				logger.Args("recommendation", "use the --branch flag to pin to a branch"))
 		}
 	}
 
 	if err != nil {
-		logger.Error("Unable to get sha of Version", logger.Args("version:", version), logger.Args("error:", err))
+		logger.Error("Unable to get sha of Version", logger.Args("version:", version), logger.Args("reason:", describeResolveError(err), "error:", err))
 		os.Exit(0)
 	}
 	pinnableAction := fmt.Sprintf("%s@%s #%s", repository, strings.TrimSpace(shaCommit), strings.TrimSpace(tagVersion))
@@ -103,56 +148,202 @@ func ActionsPin(_ *cobra.Command, _ []string) {
 	// fmt.Println(string(shaCommit.String()))
 }
 
+// initResolution parses the resolution flags shared by every command and sets up the resolver,
+// exiting on invalid flags.
+func initResolution() {
+	var err error
+	prereleasePolicy, err = pkg.ParsePrereleasePolicy(prerelease)
+	if err != nil {
+		logger.Fatal("Invalid --prerelease", logger.Args("error:", err))
+	}
+	minAge, err = pkg.ParseAge(minAgeFlag)
+	if err != nil {
+		logger.Fatal("Invalid --min-age", logger.Args("error:", err))
+	}
+	asOf = time.Time{}
+	if asOfFlag != "" {
+		asOf, err = pkg.ParseDate(asOfFlag)
+		if err != nil {
+			logger.Fatal("Invalid --as-of", logger.Args("error:", err))
+		}
+	}
+	resolver, err = newResolver()
+	if err != nil {
+		logger.Fatal("Unable to set up resolver", logger.Args("backend", backend, "error:", err))
+	}
+}
+
+// initLogger sets the package logger to debug level when --debug is set, warn otherwise.
+func initLogger() {
+	if debug {
+		logger = pterm.DefaultLogger.WithLevel(pterm.LogLevelDebug)
+	} else {
+		logger = pterm.DefaultLogger.WithLevel(pterm.LogLevelWarn)
+	}
+}
+
 func GetActionHashByVersion(repository string, version string) (string, string, error) {
-	var tagVersionBuffer bytes.Buffer
 	var tagVersion string
-	var stdErr bytes.Buffer
 	var err error
 	repository = pkg.ExtractOwnerRepo(repository)
 	// Remove 'v' from the version string if sent through command line
 	version = strings.TrimPrefix(version, "v")
-	// Check to see if value received is latest version or a specific version
+	var sha string
+	if floating && floatingTagRegexp.MatchString(version) {
+		sha, tagVersion, err = GetFloatingTagHash(repository, "v"+version)
+		if errors.Is(err, pkg.ErrNotFound) {
+			logger.Warn("Floating tag does not exist; resolving the highest matching version instead", logger.Args("repository", repository, "tag", "v"+version))
+			sha, err = "", nil
+		} else if err != nil {
+			return "", tagVersion, err
+		}
+	}
+	if sha == "" {
+		// Check to see if value received is latest version or a specific version
+		if version == latestVersion || version == "" {
+			tagVersion, err = GetLatestTag(repository)
+		} else {
+			tagVersion, err = GetLatestPatchVersion(repository, version)
+		}
+		if err != nil {
+			logger.Error("Unable to get latest release tag", logger.Args("error:", err))
+			return "", tagVersion, err
+		}
+		sha, err = resolver.ResolveTag(repository, strings.TrimSpace(tagVersion))
+		if errors.Is(err, pkg.ErrNotFound) {
+			return "", tagVersion, errors.New("version tag does not exist")
+		}
+		if err != nil {
+			return "", tagVersion, err
+		}
+	}
+	if cutoff := resolutionCutoff(); !cutoff.IsZero() {
+		return GetEligibleVersion(repository, version, sha, tagVersion, cutoff)
+	}
+	return sha, tagVersion, nil
+}
+
+// CheckSignature returns an error when --require-signed is set and the commit sha, resolved
+// from ref, has no verified signature. Repositories of --signed-exempt-owner owners pass
+// without a lookup.
+func CheckSignature(repository string, sha string, ref string) error {
+	if !requireSigned {
+		return nil
+	}
+	repository = pkg.ExtractOwnerRepo(repository)
+	owner, _, _ := strings.Cut(repository, "/")
+	for _, exempt := range signedExemptOwners {
+		if strings.EqualFold(owner, strings.TrimSpace(exempt)) {
+			return nil
+		}
+	}
+	verification, err := resolver.CommitVerification(repository, sha)
+	if err != nil {
+		return fmt.Errorf("checking the signature of %s@%s: %w", repository, ref, err)
+	}
+	if !verification.Verified {
+		return fmt.Errorf("%s@%s resolves to commit %s, which has no verified signature (%s)", repository, ref, sha, verification.Reason)
+	}
+	return nil
+}
+
+// resolutionCutoff returns the latest publication date a pinned version may have under --min-age
+// and --as-of, the earlier of the two when both are set, or the zero time when every version
+// is eligible.
+func resolutionCutoff() time.Time {
+	cutoff := asOf
+	if minAge > 0 {
+		if minAgeCutoff := time.Now().Add(-minAge); cutoff.IsZero() || minAgeCutoff.Before(cutoff) {
+			cutoff = minAgeCutoff
+		}
+	}
+	return cutoff
+}
+
+// GetEligibleVersion returns sha and tag when the version was published no later than cutoff.
+// Otherwise it falls back to the newest lower version matching the same request that was,
+// logging the versions it skipped. An exact version has nothing to fall back to and fails
+// instead.
+func GetEligibleVersion(repository string, version string, sha string, tag string, cutoff time.Time) (string, string, error) {
+	date, err := versionDate(repository, tag, sha)
+	if err != nil {
+		return "", tag, fmt.Errorf("checking the publication date of %s: %w", tag, err)
+	}
+	if !date.After(cutoff) {
+		return sha, tag, nil
+	}
+	if pkg.IsExactVersion(version) {
+		return "", tag, fmt.Errorf("%s was published on %s, which is too recent to pin", tag, date.Format(time.RFC3339))
+	}
+	expr := version
 	if version == latestVersion || version == "" {
-		tagVersionBuffer, stdErr, err = gh.Exec("release", "view", "-R", repository, "--json", "tagName", "--jq", ".tagName")
-		tagVersion = tagVersionBuffer.String()
-	} else {
-		tagVersion, err = GetLatestPatchVersion(repository, version)
+		expr = "*"
 	}
+	constraint, err := pkg.ParseConstraint(expr)
 	if err != nil {
-		logger.Error("Unable to get latest release tag", logger.Args("error:", stdErr.String()))
-		return "", tagVersion, err
+		return "", tag, err
 	}
-	// for me the cliArgs to get the commit sha based on the tag Version
-	cliArgs := fmt.Sprintf(".[] | select(.name == \"%s\") | .commit.sha", strings.TrimSpace(tagVersion))
-	cliOptions := fmt.Sprintf("repos/%s/tags", repository)
-	shaCommit, stdErr, err := gh.Exec("api", cliOptions, "--jq", cliArgs)
+	tags, err := resolver.ListTags(repository)
 	if err != nil {
-		return "", tagVersion, err
+		return "", tag, err
 	}
-	if shaCommit.String() == "" {
-		// logger.Error("Version tag does not exist, no hash found", logger.Args("version", tagVersion))
-		return "", tagVersion, errors.New("version tag does not exist")
+	shas := make(map[string]string, len(tags))
+	for _, t := range tags {
+		shas[t.Name] = t.SHA
 	}
-	sha := strings.TrimSpace(shaCommit.String())
-	return sha, tagVersion, nil
+	// Only versions below the one resolved are candidates, since anything above it was not
+	// eligible in the first place (e.g. tags newer than the latest release).
+	current, currentErr := pkg.ParseSemver(tag)
+	skipped := []string{tag}
+	for _, name := range pkg.SortVersionTags(pkg.TagNames(tags)) {
+		candidate, _ := pkg.ParseSemver(name)
+		if currentErr == nil && candidate.Compare(current) >= 0 {
+			continue
+		}
+		if !constraint.CheckWithPolicy(candidate, prereleasePolicy) {
+			continue
+		}
+		date, err := versionDate(repository, name, shas[name])
+		if err != nil {
+			return "", tag, fmt.Errorf("checking the publication date of %s: %w", name, err)
+		}
+		if date.After(cutoff) {
+			skipped = append(skipped, name)
+			continue
+		}
+		logger.Warn("Skipped versions that are too recent to pin", logger.Args("repository", repository, "skipped", strings.Join(skipped, ", "), "pinned", name))
+		return shas[name], name, nil
+	}
+	return "", tag, fmt.Errorf("no version of %s matching %s is old enough to pin (skipped %s)", repository, expr, strings.Join(skipped, ", "))
+}
+
+// versionDate returns when tag was published: the date GitHub recorded for its release, or the
+// tagger date of an annotated tag. Only tags with neither fall back to the committer date of
+// sha, which whoever made the commit can backdate.
+func versionDate(repository, tag, sha string) (time.Time, error) {
+	date, err := resolver.TagDate(repository, tag)
+	if errors.Is(err, pkg.ErrNotFound) {
+		return resolver.CommitDate(repository, sha)
+	}
+	return date, err
 }
 
 func GetLatestPatchVersion(repository string, version string) (string, error) {
-	if strings.Count(version, ".") == 2 {
-		return fmt.Sprintf("v%s", version), nil
+	// A fully specified version, including any pre-release or build suffix, names its tag directly.
+	if pkg.IsExactVersion(version) {
+		return fmt.Sprintf("v%s", strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(version), "="), "v")), nil
 	}
-	cliArgs := ".[] | .name"
-	cliOptions := fmt.Sprintf("repos/%s/tags", repository)
-	tagsBuffer, stdErr, err := gh.Exec("api", cliOptions, "--jq", cliArgs)
+	tags, err := resolver.ListTags(repository)
 	if err != nil {
-		logger.Error("Issue with gh api and getting specific major.minor.version tag", logger.Args("error:", stdErr.String(), "action", fmt.Sprintf("%s@%s", repository, version)))
+		logger.Error("Issue listing tags and getting specific major.minor.version tag", logger.Args("error:", err, "action", fmt.Sprintf("%s@%s", repository, version)))
 		return fmt.Sprintf("v%s", version), err
 	}
 
-	tagsString := tagsBuffer.String()
-	tags := strings.Split(tagsString, "\n")
-
-	newVersion, err := pkg.FindHighestPatchVersion(tags, version)
+	constraint, err := pkg.ParseConstraint(version)
+	if err != nil {
+		return "", err
+	}
+	newVersion, err := pkg.HighestMatchingTag(pkg.TagNames(tags), constraint, prereleasePolicy)
 	if err != nil {
 		logger.Error("Unable to find highest patch version", logger.Args("error:", err))
 		return "", err
@@ -162,14 +353,147 @@ func GetLatestPatchVersion(repository string, version string) (string, error) {
 
 }
 
+// GetFloatingTagHash returns the commit a floating tag such as v4 points at, along with the
+// highest full semver tag on the same commit for the pin comment. When no full tag shares the
+// commit it warns and returns the floating tag itself.
+func GetFloatingTagHash(repository string, tag string) (string, string, error) {
+	sha, err := resolver.ResolveTag(repository, tag)
+	if err != nil {
+		return "", tag, err
+	}
+	tags, err := resolver.ListTags(repository)
+	if err != nil {
+		logger.Warn("Unable to list tags to find the release of a floating tag", logger.Args("repository", repository, "tag", tag, "error:", err))
+		return sha, tag, nil
+	}
+	exact, ok := pkg.ExactTagForSHA(tags, sha)
+	if !ok {
+		logger.Warn("No release tag points at the floating tag's commit; commenting the floating tag", logger.Args("repository", repository, "tag", tag, "sha", sha))
+		return sha, tag, nil
+	}
+	return sha, exact, nil
+}
+
+// GetLatestTag returns the tag "latest" resolves to under the --prerelease policy. The latest
+// release is never a pre-release, so with include or only the tags are scanned for newer
+// pre-releases; include keeps the latest release unless a pre-release tag outranks it.
+func GetLatestTag(repository string) (string, error) {
+	if prereleasePolicy != pkg.PrereleaseInclude && prereleasePolicy != pkg.PrereleaseOnly {
+		return resolver.LatestRelease(repository)
+	}
+	var release string
+	if prereleasePolicy == pkg.PrereleaseInclude {
+		var err error
+		release, err = resolver.LatestRelease(repository)
+		if err != nil && !errors.Is(err, pkg.ErrNotFound) {
+			return "", err
+		}
+	}
+	tags, err := resolver.ListTags(repository)
+	if err != nil {
+		return "", err
+	}
+	anyVersion, _ := pkg.ParseConstraint("*")
+	newest, err := pkg.HighestMatchingTag(pkg.TagNames(tags), anyVersion, pkg.PrereleaseOnly)
+	if err != nil {
+		if release != "" {
+			return release, nil
+		}
+		return "", fmt.Errorf("%w: %s has no pre-release tags", pkg.ErrNotFound, repository)
+	}
+	if release != "" {
+		releaseVersion, releaseErr := pkg.ParseSemver(release)
+		newestVersion, _ := pkg.ParseSemver(newest)
+		if releaseErr != nil || releaseVersion.Compare(newestVersion) >= 0 {
+			return release, nil
+		}
+	}
+	return newest, nil
+}
+
 func GetBranchHash(repository string, branch string) (string, error) {
 	repository = pkg.ExtractOwnerRepo(repository)
-	cliArgs := ".commit.sha"
-	cliOptions := fmt.Sprintf("repos/%s/branches/%s", repository, branch)
-	shaCommit, stdErr, err := gh.Exec("api", cliOptions, "--jq", cliArgs)
+	shaCommit, err := resolver.ResolveBranch(repository, branch)
 	if err != nil {
-		logger.Error("Issue with gh api and getting specific branch hash", logger.Args("error:", stdErr.String(), "action", fmt.Sprintf("%s@%s", repository, branch)))
+		logger.Error("Issue getting specific branch hash", logger.Args("error:", err, "action", fmt.Sprintf("%s@%s", repository, branch)))
 		return "", err
 	}
-	return shaCommit.String(), nil
+	return shaCommit, nil
+}
+
+// newRetryTransport returns the rate-limit aware transport for GitHub API requests, logging
+// the remaining quota in debug mode and every retry.
+func newRetryTransport() *pkg.RetryTransport {
+	transport := pkg.NewRetryTransport(nil)
+	transport.MaxRetries = maxRetries
+	transport.OnQuota = func(quota pkg.RateLimit) {
+		logger.Debug("GitHub API quota", logger.Args("resource", quota.Resource, "remaining", quota.Remaining,
+			"limit", quota.Limit, "reset", quota.Reset.Format(time.RFC3339)))
+	}
+	transport.OnRetry = func(attempt int, wait time.Duration) {
+		logger.Warn("Rate limited by GitHub API; retrying", logger.Args("attempt", attempt, "wait", wait.String()))
+	}
+	return transport
+}
+
+// describeResolveError gives a short, user-facing reason for a resolution failure.
+func describeResolveError(err error) string {
+	switch {
+	case errors.Is(err, pkg.ErrRateLimited):
+		return "GitHub API rate limit exceeded"
+	case errors.Is(err, pkg.ErrUnauthorized):
+		return "GitHub rejected the credentials, try gh auth login"
+	case errors.Is(err, pkg.ErrNotFound):
+		return "repository or ref not found"
+	}
+	return err.Error()
+}
+
+// newResolver returns the Resolver used to look up action refs for the selected --backend,
+// or the offline mirror resolver when --mirror-dir is set. Online backends are wrapped in the
+// persistent cache unless --no-cache is set.
+func newResolver() (pkg.Resolver, error) {
+	if mirrorDir != "" {
+		return pkg.NewMirrorResolver(mirrorDir), nil
+	}
+	host := resolutionHost()
+	var base pkg.Resolver
+	switch backend {
+	case backendREST:
+		restResolver, err := pkg.NewRESTResolver(api.ClientOptions{Host: host, Transport: newRetryTransport()})
+		if err != nil {
+			return nil, err
+		}
+		base = restResolver
+	case backendGh:
+		ghResolver := pkg.NewGhResolver()
+		ghResolver.Hostname = host
+		ghResolver.MaxRetries = maxRetries
+		base = ghResolver
+	default:
+		return nil, fmt.Errorf("unknown backend %q, expected %s or %s", backend, backendREST, backendGh)
+	}
+	if noCache {
+		return base, nil
+	}
+	cacheDir, err := pkg.DefaultCacheDir()
+	if err != nil {
+		logger.Debug("Resolution cache disabled", logger.Args("error:", err))
+		return base, nil
+	}
+	if cacheCommitsOnly {
+		return pkg.NewCommitCachingResolver(base, cacheDir, backend+"@"+host, cacheTTL), nil
+	}
+	return pkg.NewCachingResolver(base, cacheDir, backend+"@"+host, cacheTTL), nil
+}
+
+// resolutionHost returns the GitHub host actions are resolved against: --hostname when set,
+// otherwise GH_HOST or the gh default host. Setting --hostname github.com resolves public
+// actions even when GH_HOST points at an enterprise server.
+func resolutionHost() string {
+	if hostname != "" {
+		return hostname
+	}
+	host, _ := auth.DefaultHost()
+	return host
 }
@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amenocal/gh-pin-actions/pkg"
+)
+
+const (
+	testShaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	testShaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	testShaC = "cccccccccccccccccccccccccccccccccccccccc"
+	testShaD = "dddddddddddddddddddddddddddddddddddddddd"
+)
+
+// newTestResolver returns a MemoryResolver with a small actions/checkout fixture.
+func newTestResolver() *pkg.MemoryResolver {
+	return &pkg.MemoryResolver{
+		Tags: map[string][]pkg.Tag{
+			"actions/checkout": {
+				{Name: "v5.0.0-beta.1", SHA: testShaD},
+				{Name: "v4.2.2", SHA: testShaA},
+				{Name: "v4.1.7", SHA: testShaB},
+				{Name: "v3.6.0", SHA: testShaC},
+			},
+		},
+		Branches: map[string]map[string]string{
+			"actions/checkout": {"main": testShaD},
+		},
+		Releases: map[string]string{
+			"actions/checkout": "v4.2.2",
+		},
+	}
+}
+
+func TestGetActionHashByVersion(t *testing.T) {
+	resolver = newTestResolver()
+	tests := []struct {
+		name       string
+		repository string
+		version    string
+		wantSha    string
+		wantTag    string
+		wantErr    bool
+	}{
+		{name: "latest release", repository: "actions/checkout", version: "latest", wantSha: testShaA, wantTag: "v4.2.2"},
+		{name: "major version", repository: "actions/checkout", version: "3", wantSha: testShaC, wantTag: "v3.6.0"},
+		{name: "major.minor version", repository: "actions/checkout", version: "v4.1", wantSha: testShaB, wantTag: "v4.1.7"},
+		{name: "exact version", repository: "actions/checkout", version: "4.2.2", wantSha: testShaA, wantTag: "v4.2.2"},
+		{name: "sub-path action", repository: "actions/checkout/sub", version: "4", wantSha: testShaA, wantTag: "v4.2.2"},
+		{name: "exact pre-release version", repository: "actions/checkout", version: "v5.0.0-beta.1", wantSha: testShaD, wantTag: "v5.0.0-beta.1"},
+		{name: "major with only pre-releases", repository: "actions/checkout", version: "5", wantErr: true},
+		{name: "caret constraint", repository: "actions/checkout", version: "^4.1", wantSha: testShaA, wantTag: "v4.2.2"},
+		{name: "range constraint", repository: "actions/checkout", version: ">=3 <4.2", wantSha: testShaB, wantTag: "v4.1.7"},
+		{name: "exclusion constraint", repository: "actions/checkout", version: "4 !=4.2.2", wantSha: testShaB, wantTag: "v4.1.7"},
+		{name: "pre-release constraint", repository: "actions/checkout", version: ">=5.0.0-beta.1", wantSha: testShaD, wantTag: "v5.0.0-beta.1"},
+		{name: "unsatisfiable constraint", repository: "actions/checkout", version: "~3.7", wantErr: true},
+		{name: "missing exact tag", repository: "actions/checkout", version: "9.9.9", wantErr: true},
+		{name: "unknown repository", repository: "actions/missing", version: "latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sha, tag, err := GetActionHashByVersion(tt.repository, tt.version)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetActionHashByVersion(%q, %q) expected error, got nil", tt.repository, tt.version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetActionHashByVersion(%q, %q) unexpected error: %v", tt.repository, tt.version, err)
+			}
+			if sha != tt.wantSha || tag != tt.wantTag {
+				t.Errorf("GetActionHashByVersion(%q, %q) = (%q, %q), want (%q, %q)", tt.repository, tt.version, sha, tag, tt.wantSha, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestGetBranchHash(t *testing.T) {
+	resolver = newTestResolver()
+	sha, err := GetBranchHash("actions/checkout", "main")
+	if err != nil {
+		t.Fatalf("GetBranchHash unexpected error: %v", err)
+	}
+	if sha != testShaD {
+		t.Errorf("GetBranchHash = %q, want %q", sha, testShaD)
+	}
+	if _, err := GetBranchHash("actions/checkout", "missing"); err == nil {
+		t.Errorf("GetBranchHash for missing branch expected error, got nil")
+	}
+}
+
+func TestResolutionHost(t *testing.T) {
+	t.Setenv("GH_HOST", "ghes.example.com")
+	defer func() { hostname = "" }()
+
+	hostname = ""
+	if got := resolutionHost(); got != "ghes.example.com" {
+		t.Errorf("resolutionHost() with GH_HOST = %q, want %q", got, "ghes.example.com")
+	}
+	hostname = "github.com"
+	if got := resolutionHost(); got != "github.com" {
+		t.Errorf("resolutionHost() with --hostname = %q, want %q", got, "github.com")
+	}
+}
+
+func TestGetLatestTag(t *testing.T) {
+	resolver = newTestResolver()
+	defer func() { prereleasePolicy = pkg.PrereleaseExclude }()
+
+	tests := []struct {
+		name    string
+		policy  pkg.PrereleasePolicy
+		want    string
+		wantErr bool
+	}{
+		{name: "exclude uses the latest release", policy: pkg.PrereleaseExclude, want: "v4.2.2"},
+		{name: "include prefers a newer pre-release", policy: pkg.PrereleaseInclude, want: "v5.0.0-beta.1"},
+		{name: "only picks the newest pre-release", policy: pkg.PrereleaseOnly, want: "v5.0.0-beta.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prereleasePolicy = tt.policy
+			got, err := GetLatestTag("actions/checkout")
+			if err != nil {
+				t.Fatalf("GetLatestTag unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetLatestTag with %s = %q, want %q", tt.policy, got, tt.want)
+			}
+		})
+	}
+
+	// A release newer than every pre-release wins under include; only has nothing to offer.
+	resolver = &pkg.MemoryResolver{
+		Tags: map[string][]pkg.Tag{
+			"actions/setup-go": {{Name: "v5.1.0", SHA: testShaA}, {Name: "v5.1.0-rc.1", SHA: testShaB}},
+			"actions/cache":    {{Name: "v4.0.0", SHA: testShaC}},
+		},
+		Releases: map[string]string{"actions/setup-go": "v5.1.0", "actions/cache": "v4.0.0"},
+	}
+	prereleasePolicy = pkg.PrereleaseInclude
+	if got, err := GetLatestTag("actions/setup-go"); err != nil || got != "v5.1.0" {
+		t.Errorf("GetLatestTag with include = (%q, %v), want %q", got, err, "v5.1.0")
+	}
+	if got, err := GetLatestTag("actions/cache"); err != nil || got != "v4.0.0" {
+		t.Errorf("GetLatestTag with include and no pre-releases = (%q, %v), want %q", got, err, "v4.0.0")
+	}
+	prereleasePolicy = pkg.PrereleaseOnly
+	if _, err := GetLatestTag("actions/cache"); err == nil {
+		t.Errorf("GetLatestTag with only and no pre-releases expected error, got nil")
+	}
+}
+
+func TestGetActionHashByVersionPrereleasePolicy(t *testing.T) {
+	resolver = newTestResolver()
+	defer func() { prereleasePolicy = pkg.PrereleaseExclude }()
+
+	prereleasePolicy = pkg.PrereleaseInclude
+	sha, tag, err := GetActionHashByVersion("actions/checkout", "5")
+	if err != nil {
+		t.Fatalf("GetActionHashByVersion with include unexpected error: %v", err)
+	}
+	if sha != testShaD || tag != "v5.0.0-beta.1" {
+		t.Errorf("GetActionHashByVersion with include = (%q, %q), want (%q, %q)", sha, tag, testShaD, "v5.0.0-beta.1")
+	}
+
+	prereleasePolicy = pkg.PrereleaseOnly
+	if _, _, err := GetActionHashByVersion("actions/checkout", "4"); err == nil {
+		t.Errorf("GetActionHashByVersion with only and no 4.x pre-releases expected error, got nil")
+	}
+}
+
+func TestGetActionHashByVersionFloating(t *testing.T) {
+	// The moving v4 tag points at v4.1.7 rather than the newest v4.2.2; v3 has no release on its commit.
+	resolver = &pkg.MemoryResolver{
+		Tags: map[string][]pkg.Tag{
+			"actions/checkout": {
+				{Name: "v4", SHA: testShaB},
+				{Name: "v4.2.2", SHA: testShaA},
+				{Name: "v4.1.7", SHA: testShaB},
+				{Name: "v3", SHA: testShaD},
+				{Name: "v3.6.0", SHA: testShaC},
+			},
+		},
+	}
+	floating = true
+	defer func() { floating = false }()
+
+	tests := []struct {
+		name    string
+		version string
+		wantSha string
+		wantTag string
+	}{
+		{name: "floating tag commented with its release", version: "4", wantSha: testShaB, wantTag: "v4.1.7"},
+		{name: "floating tag without a release", version: "v3", wantSha: testShaD, wantTag: "v3"},
+		{name: "missing floating tag falls back", version: "4.2", wantSha: testShaA, wantTag: "v4.2.2"},
+		{name: "exact version unaffected", version: "4.2.2", wantSha: testShaA, wantTag: "v4.2.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sha, tag, err := GetActionHashByVersion("actions/checkout", tt.version)
+			if err != nil {
+				t.Fatalf("GetActionHashByVersion(%q) unexpected error: %v", tt.version, err)
+			}
+			if sha != tt.wantSha || tag != tt.wantTag {
+				t.Errorf("GetActionHashByVersion(%q) = (%q, %q), want (%q, %q)", tt.version, sha, tag, tt.wantSha, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestGetActionHashByVersionMinAge(t *testing.T) {
+	r := newTestResolver()
+	now := time.Now()
+	r.CommitDates = map[string]time.Time{
+		testShaA: now.Add(-24 * time.Hour),
+		testShaB: now.Add(-30 * 24 * time.Hour),
+		testShaC: now.Add(-60 * 24 * time.Hour),
+		testShaD: now.Add(-24 * time.Hour),
+	}
+	resolver = r
+	minAge = 7 * 24 * time.Hour
+	defer func() { minAge = 0 }()
+
+	tests := []struct {
+		name    string
+		version string
+		wantSha string
+		wantTag string
+		wantErr bool
+	}{
+		{name: "too recent patch falls back", version: "4", wantSha: testShaB, wantTag: "v4.1.7"},
+		{name: "too recent latest release falls back", version: "latest", wantSha: testShaB, wantTag: "v4.1.7"},
+		{name: "old enough version kept", version: "3", wantSha: testShaC, wantTag: "v3.6.0"},
+		{name: "too recent exact version", version: "4.2.2", wantErr: true},
+		{name: "no eligible version", version: "^4.2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sha, tag, err := GetActionHashByVersion("actions/checkout", tt.version)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetActionHashByVersion(%q) expected error, got (%q, %q)", tt.version, sha, tag)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetActionHashByVersion(%q) unexpected error: %v", tt.version, err)
+			}
+			if sha != tt.wantSha || tag != tt.wantTag {
+				t.Errorf("GetActionHashByVersion(%q) = (%q, %q), want (%q, %q)", tt.version, sha, tag, tt.wantSha, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestGetActionHashByVersionMinAgePublished(t *testing.T) {
+	r := newTestResolver()
+	now := time.Now()
+	// Every commit is backdated, but v4.2.2 was only released yesterday.
+	r.CommitDates = map[string]time.Time{
+		testShaA: now.Add(-90 * 24 * time.Hour),
+		testShaB: now.Add(-90 * 24 * time.Hour),
+		testShaC: now.Add(-90 * 24 * time.Hour),
+	}
+	r.TagDates = map[string]map[string]time.Time{
+		"actions/checkout": {"v4.2.2": now.Add(-24 * time.Hour)},
+	}
+	resolver = r
+	minAge = 7 * 24 * time.Hour
+	defer func() { minAge = 0 }()
+
+	if sha, tag, err := GetActionHashByVersion("actions/checkout", "4"); err != nil || sha != testShaB || tag != "v4.1.7" {
+		t.Errorf("GetActionHashByVersion(4) = (%q, %q, %v), want (%q, %q, nil)", sha, tag, err, testShaB, "v4.1.7")
+	}
+	if _, _, err := GetActionHashByVersion("actions/checkout", "4.2.2"); err == nil {
+		t.Errorf("GetActionHashByVersion(4.2.2) released yesterday on a backdated commit expected error, got nil")
+	}
+}
+
+func TestGetActionHashByVersionAsOf(t *testing.T) {
+	r := newTestResolver()
+	r.CommitDates = map[string]time.Time{
+		testShaA: time.Date(2026, 4, 10, 0, 0, 0, 0, time.UTC),
+		testShaB: time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC),
+		testShaC: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC),
+		testShaD: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+	resolver = r
+	defer func() { asOf = time.Time{} }()
+
+	asOf = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, version := range []string{"4", "latest"} {
+		sha, tag, err := GetActionHashByVersion("actions/checkout", version)
+		if err != nil || sha != testShaB || tag != "v4.1.7" {
+			t.Errorf("GetActionHashByVersion(%q) as of %s = (%q, %q, %v), want (%q, %q, nil)", version, asOf, sha, tag, err, testShaB, "v4.1.7")
+		}
+	}
+
+	asOf = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, _, err := GetActionHashByVersion("actions/checkout", "4"); err == nil {
+		t.Errorf("GetActionHashByVersion(%q) as of %s expected error, got nil", "4", asOf)
+	}
+	if sha, tag, err := GetActionHashByVersion("actions/checkout", "latest"); err != nil || sha != testShaC || tag != "v3.6.0" {
+		t.Errorf("GetActionHashByVersion(latest) as of %s = (%q, %q, %v), want (%q, %q, nil)", asOf, sha, tag, err, testShaC, "v3.6.0")
+	}
+}
+
+func TestResolutionCutoff(t *testing.T) {
+	defer func() { asOf, minAge = time.Time{}, 0 }()
+
+	asOf, minAge = time.Time{}, 0
+	if got := resolutionCutoff(); !got.IsZero() {
+		t.Errorf("resolutionCutoff() without flags = %v, want zero", got)
+	}
+	asOf = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got := resolutionCutoff(); !got.Equal(asOf) {
+		t.Errorf("resolutionCutoff() with --as-of = %v, want %v", got, asOf)
+	}
+	// --min-age only tightens --as-of when it reaches further back.
+	minAge = 7 * 24 * time.Hour
+	if got := resolutionCutoff(); !got.Equal(asOf) {
+		t.Errorf("resolutionCutoff() with an older --as-of = %v, want %v", got, asOf)
+	}
+	asOf = time.Now().Add(time.Hour)
+	if got := resolutionCutoff(); time.Since(got) < minAge {
+		t.Errorf("resolutionCutoff() with a recent --as-of = %v, want at least %v ago", got, minAge)
+	}
+}
+
+func TestCheckSignature(t *testing.T) {
+	r := newTestResolver()
+	r.Verifications = map[string]pkg.Verification{testShaA: {Verified: true, Reason: "valid"}}
+	resolver = r
+	defer func() { requireSigned, signedExemptOwners = false, nil }()
+
+	tests := []struct {
+		name          string
+		repository    string
+		sha           string
+		requireSigned bool
+		exempt        []string
+		wantErr       bool
+	}{
+		{name: "not required", repository: "actions/checkout", sha: testShaB},
+		{name: "verified commit", repository: "actions/checkout", sha: testShaA, requireSigned: true},
+		{name: "unsigned commit", repository: "actions/checkout", sha: testShaB, requireSigned: true, wantErr: true},
+		{name: "exempt owner", repository: "actions/checkout/sub", sha: testShaB, requireSigned: true, exempt: []string{"github", "Actions"}},
+		{name: "other owner exempt", repository: "actions/checkout", sha: testShaB, requireSigned: true, exempt: []string{"github"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requireSigned, signedExemptOwners = tt.requireSigned, tt.exempt
+			err := CheckSignature(tt.repository, tt.sha, "v4")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckSignature(%q, %q) error = %v, wantErr %v", tt.repository, tt.sha, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/amenocal/gh-pin-actions/pkg"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Checks that every SHA-pinned action matches the version in its comment",
+	Long: `Reads every workflow file in .github/workflows and checks that each action pinned to a sha
+		with a comment such as # v3.5.0 or # main really is that ref: a tag must point to the pinned
+		commit and a branch must contain it. Mismatches are reported per file and line and the command
+		exits with a non-zero status`,
+	Run: verifyWorkflows,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func verifyWorkflows(_ *cobra.Command, _ []string) {
+	initLogger()
+	// Branch heads move, so containment is checked against their current state.
+	noCache = true
+	initResolution()
+
+	workflowFiles, err := getPinnableFiles()
+	if err != nil {
+		logger.Fatal("Error reading .github/workflow files", logger.Args("error:", err))
+	}
+	reportFindings(verifyPinnedRefs(workflowFiles), "pinned action(s) that don't match their comment", "Every pinned action matches its comment")
+}
+
+// verifyPinnedRefs checks each SHA pin in files against the ref named by its comment, which is
+// looked up as a tag first and then as a branch. Pins without a comment are skipped.
+func verifyPinnedRefs(files []string) []auditFinding {
+	var findings []auditFinding
+	for _, file := range files {
+		refs, err := readPinnedRefs(file)
+		if err != nil {
+			logger.Warn("Error reading workflow; skipping", logger.Args("file:", file, "error:", err))
+			continue
+		}
+		for _, ref := range refs {
+			if ref.Comment == "" {
+				logger.Debug("Pinned action has no comment to verify", logger.Args("file:", file, "line", ref.Line, "action:", ref.Action))
+				continue
+			}
+			message, err := verifyPinnedRef(ref)
+			if err != nil {
+				logger.Warn("Unable to verify pinned action; skipping", logger.Args("file:", file, "line", ref.Line, "action:", ref.Action, "reason:", describeResolveError(err)))
+				continue
+			}
+			if message != "" {
+				findings = append(findings, auditFinding{file: file, ref: ref, message: message})
+			}
+		}
+	}
+	return findings
+}
+
+// verifyPinnedRef returns why ref's SHA doesn't match its comment, or "" when it does.
+func verifyPinnedRef(ref pkg.PinnedRef) (string, error) {
+	repository := pkg.ExtractOwnerRepo(ref.Repository)
+	sha, err := resolver.ResolveTag(repository, ref.Comment)
+	if err == nil {
+		if sha != ref.SHA {
+			return fmt.Sprintf("tag %s points to %s, not the pinned commit", ref.Comment, sha), nil
+		}
+		return "", nil
+	}
+	if !errors.Is(err, pkg.ErrNotFound) {
+		return "", err
+	}
+	head, err := resolver.ResolveBranch(repository, ref.Comment)
+	if errors.Is(err, pkg.ErrNotFound) {
+		return fmt.Sprintf("%s is neither a tag nor a branch of %s", ref.Comment, repository), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if head == ref.SHA {
+		return "", nil
+	}
+	contains, err := resolver.RefContains(repository, ref.Comment, ref.SHA)
+	if err != nil {
+		return "", err
+	}
+	if !contains {
+		return fmt.Sprintf("branch %s does not contain the pinned commit", ref.Comment), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyPinnedRefs(t *testing.T) {
+	const historySha = "1111111111111111111111111111111111111111"
+	r := newTestResolver()
+	r.History = map[string][]string{"actions/checkout": {historySha}}
+	resolver = r
+
+	workflow := filepath.Join(t.TempDir(), "ci.yml")
+	content := "jobs:\n" +
+		"  build:\n" +
+		"    steps:\n" +
+		"      - uses: actions/checkout@" + testShaA + " # v4.2.2\n" +
+		"      - uses: actions/checkout@" + testShaC + " # v3.5.0\n" +
+		"      - uses: actions/checkout@" + testShaB + " # v3.6.0\n" +
+		"      - uses: actions/checkout/sub@" + testShaD + " # main\n" +
+		"      - uses: actions/checkout@" + historySha + " # main\n" +
+		"      - uses: actions/checkout@" + testShaA + " # main\n" +
+		"      - uses: actions/checkout@" + testShaA + "\n" +
+		"      - run: |\n" +
+		"          # uses: actions/checkout@" + testShaB + " # v4.2.2\n" +
+		"          echo uses: actions/checkout@" + testShaB + " # v4.2.2\n"
+	if err := os.WriteFile(workflow, []byte(content), 0644); err != nil {
+		t.Fatalf("Unexpected error writing workflow: %v", err)
+	}
+
+	findings := verifyPinnedRefs([]string{workflow})
+	want := []struct {
+		line    int
+		message string
+	}{
+		{line: 5, message: "v3.5.0 is neither a tag nor a branch"},
+		{line: 6, message: "tag v3.6.0 points to " + testShaC},
+		{line: 9, message: "branch main does not contain the pinned commit"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("verifyPinnedRefs returned %d findings, want %d: %+v", len(findings), len(want), findings)
+	}
+	for i, w := range want {
+		if findings[i].ref.Line != w.line || !strings.Contains(findings[i].message, w.message) {
+			t.Errorf("finding %d = line %d %q, want line %d %q", i, findings[i].ref.Line, findings[i].message, w.line, w.message)
+		}
+	}
+}
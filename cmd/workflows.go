@@ -1,18 +1,22 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pterm/pterm"
 
 	"github.com/amenocal/gh-pin-actions/pkg"
+	"github.com/cli/go-gh/v2/pkg/api"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -26,23 +30,38 @@ var (
 	logger             *pterm.Logger
 	overwriteWorkflows bool
 	pinLatest          bool
+	concurrency        int
+	constraintFlags    []string
+	impostorCheck      string
+	// actionConstraints maps owner/repo to the --constraint expression that replaces its declared version.
+	actionConstraints map[string]string
+	// images resolves container image tags to digests. It is nil with --mirror-dir, which
+	// works offline.
+	images pkg.ImageResolver
 
 	hashRegexp   = regexp.MustCompile(`@[0-9a-f]{40}`)
 	branchRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+/[a-zA-Z0-9_-]+@[a-zA-Z0-9_-]+$`)
+	// reusableWorkflowRegexp matches a reusable workflow call in another repository, such as
+	// octo-org/shared/.github/workflows/build.yml@main.
+	reusableWorkflowRegexp = regexp.MustCompile(`^[\w.-]+/[\w.-]+/\.github/workflows/[^@]+\.ya?ml@[^@]+$`)
 )
 
-type Step struct {
-	Name string `yaml:"name"`
-	Uses string `yaml:"uses"`
-}
-
-type Job struct {
-	Steps []Step `yaml:"steps"`
-}
-
-type Workflow struct {
-	Jobs map[string]Job `yaml:"jobs"`
-}
+const (
+	// defaultConcurrency is the number of resolution workers used unless --concurrency is set.
+	defaultConcurrency = 8
+	// batchThreshold is the number of actions needing resolution above which their refs are
+	// prefetched with batched GraphQL queries instead of one REST call per action.
+	batchThreshold = 5
+
+	// Values of --impostor-check, deciding what happens to SHA pins whose commit is not
+	// reachable from any branch or tag of the named repository.
+	impostorWarn   = "warn"
+	impostorRefuse = "refuse"
+	impostorOff    = "off"
+
+	// dockerPrefix marks a step that runs a container image instead of an action.
+	dockerPrefix = "docker://"
+)
 
 func init() {
 	rootCmd.AddCommand(workflowsCmd)
@@ -51,6 +70,9 @@ func init() {
 	// when this action is called directly.
 	workflowsCmd.Flags().BoolVarP(&overwriteWorkflows, "overwrite", "o", false, "overwrite existing workflow files")
 	workflowsCmd.Flags().BoolVarP(&pinLatest, "latest", "l", false, "pin actions to the latest release across all major versions instead of the declared version")
+	workflowsCmd.Flags().IntVarP(&concurrency, "concurrency", "c", defaultConcurrency, "number of actions resolved in parallel")
+	workflowsCmd.Flags().StringVar(&impostorCheck, "impostor-check", impostorWarn, "check that SHA-pinned commits are reachable from a branch or tag of their repository: warn, refuse (exit without rewriting) or off")
+	workflowsCmd.Flags().StringArrayVar(&constraintFlags, "constraint", nil, "version constraint for an action as owner/repo=EXPR, used instead of its declared version (ex. 'actions/checkout=^4 !=4.0.1'); repeatable")
 	// rootCmd.MarkFlagRequired("repository")
 
 	// rootCmd.Flags().StringVarP(&version, "version", "v", "latest", "version of the tag to pin to (ex. 3; 3.1; 3.1.1)")
@@ -60,20 +82,53 @@ func init() {
 
 func processWorkflows(_ *cobra.Command, _ []string) {
 	debug = rootCmd.Flag("debug").Value.String() == "true"
-	if debug {
-		logger = pterm.DefaultLogger.WithLevel(pterm.LogLevelDebug)
-	} else {
-		logger = pterm.DefaultLogger.WithLevel(pterm.LogLevelWarn)
+	initLogger()
+	initResolution()
+	images = nil
+	if mirrorDir == "" {
+		images = pkg.NewRegistryClient(nil)
+	}
+	var err error
+	actionConstraints, err = parseConstraintFlags(constraintFlags)
+	if err != nil {
+		logger.Fatal("Invalid --constraint", logger.Args("error:", err))
+	}
+	if impostorCheck != impostorWarn && impostorCheck != impostorRefuse && impostorCheck != impostorOff {
+		logger.Fatal("Invalid --impostor-check", logger.Args("value", impostorCheck, "allowed", "warn, refuse or off"))
 	}
 
-	workflowFiles, err := getWorkflowFiles()
+	workflowFiles, err := getPinnableFiles()
 	if err != nil {
 		logger.Error("Error reading .github/workflow files", logger.Args("error:", err))
 		return
 	}
 
+	// Collect every action reference up front so each unique one is resolved only once.
+	var parsedFiles []string
+	var uniqueActions []string
+	seen := make(map[string]bool)
 	for _, file := range workflowFiles {
-		processActionsYaml(file)
+		actions, err := readWorkflowActions(file)
+		if err != nil {
+			logger.Warn("Error reading workflow; skipping", logger.Args("file:", file, "error:", err))
+			continue
+		}
+		parsedFiles = append(parsedFiles, file)
+		for _, action := range actions {
+			if !seen[action] {
+				seen[action] = true
+				uniqueActions = append(uniqueActions, action)
+			}
+		}
+	}
+
+	prefetchActions(uniqueActions)
+	updates := resolveActions(uniqueActions, concurrency)
+	if impostors := impostorActions(updates); len(impostors) > 0 && impostorCheck == impostorRefuse {
+		logger.Fatal("Refusing to pin workflows referencing impostor or unverified commits", logger.Args("actions", strings.Join(impostors, ", ")))
+	}
+	for _, file := range parsedFiles {
+		pinWorkflowFile(file, updates)
 	}
 }
 
@@ -95,89 +150,395 @@ func getWorkflowFiles() ([]string, error) {
 	return workflowFiles, nil
 }
 
-func processActionsYaml(workflow string) {
-	var wf Workflow
-	data, err := os.ReadFile(workflow)
+// getActionFiles returns the action metadata files (action.yml or action.yaml) of the
+// repository in the current directory: the composite actions anywhere under .github/actions,
+// followed by the action at the repository root. Other directories, such as vendored code or
+// test fixtures, are not searched. Directories that can't be read are skipped with a warning.
+func getActionFiles() []string {
+	var actionFiles []string
+	root := filepath.Join(".github", "actions")
+	filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if path != root || !errors.Is(err, fs.ErrNotExist) {
+				logger.Warn("Error reading actions directory; skipping", logger.Args("path:", path, "error:", err))
+			}
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if entry.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isActionFile(path) {
+			actionFiles = append(actionFiles, path)
+		}
+		return nil
+	})
+	for _, name := range []string{"action.yml", "action.yaml"} {
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			actionFiles = append(actionFiles, name)
+		}
+	}
+	return actionFiles
+}
+
+// isActionFile reports whether file is action metadata rather than a workflow.
+func isActionFile(file string) bool {
+	name := filepath.Base(file)
+	return name == "action.yml" || name == "action.yaml"
+}
+
+// getPinnableFiles returns the workflow files followed by the action metadata files of the
+// repository in the current directory. A repository without .github/workflows, such as one
+// that only ships an action, is fine as long as it has action metadata files. Each file is
+// listed once.
+func getPinnableFiles() ([]string, error) {
+	workflowFiles, workflowErr := getWorkflowFiles()
+	if workflowErr != nil && !errors.Is(workflowErr, fs.ErrNotExist) {
+		return nil, workflowErr
+	}
+	actionFiles := getActionFiles()
+	if workflowErr != nil && len(actionFiles) == 0 {
+		return nil, workflowErr
+	}
+	files := workflowFiles
+	seen := map[string]bool{}
+	for _, file := range workflowFiles {
+		seen[file] = true
+	}
+	for _, file := range actionFiles {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// readWorkflowActions returns the uses: reference of every step in workflow, in file order.
+func readWorkflowActions(workflow string) ([]string, error) {
 	logger.Print("Processing workflow", logger.Args("file:", workflow))
+	data, err := os.ReadFile(workflow)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := findWorkflowRefs(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []string
+	for _, ref := range refs {
+		logger.Trace("Processing Step", logger.Args("line:", ref.Line, "uses:", ref.Value))
+		actions = append(actions, refAction(ref))
+	}
+	return actions, nil
+}
+
+// findWorkflowRefs returns the uses: references of a workflow followed by its job container
+// and service images.
+func findWorkflowRefs(content []byte) ([]pkg.UsesRef, error) {
+	refs, err := pkg.FindUsesRefs(content)
 	if err != nil {
-		logger.Warn("Error reading YAML", logger.Args("file:", workflow, "error:", err))
+		return nil, err
+	}
+	imageRefs, err := pkg.FindImageRefs(content)
+	if err != nil {
+		return nil, err
+	}
+	return append(refs, imageRefs...), nil
+}
 
+// refAction returns the action reference a workflow ref is resolved as. Container and service
+// images are resolved like docker:// steps.
+func refAction(ref pkg.UsesRef) string {
+	if ref.Image {
+		return dockerPrefix + ref.Value
 	}
-	err = yaml.Unmarshal(data, &wf)
+	return ref.Value
+}
+
+// pinWorkflowFile writes the resolved updates into a -pin copy of workflow, or into workflow
+// itself with --overwrite. Only the uses: values of the updated steps are rewritten. Action
+// metadata files with nothing to update, such as JavaScript and Docker actions, are left
+// without a copy.
+func pinWorkflowFile(workflow string, updates map[string]actionUpdate) {
+	content, err := os.ReadFile(workflow)
 	if err != nil {
-		logger.Warn("Error unmarshalling YAML", logger.Args("file:", workflow, "error:", err))
+		logger.Warn("Error reading file", logger.Args("file:", workflow, "error:", err))
+		return
 	}
+	refs, err := findWorkflowRefs(content)
+	if err != nil {
+		logger.Warn("Error reading workflow", logger.Args("file:", workflow, "error:", err))
+		return
+	}
+	var edits []pkg.UsesEdit
+	for _, ref := range refs {
+		update := updates[refAction(ref)]
+		if ref.Unlocated && update.updated != "" {
+			logger.Warn("Unable to locate the value in the file to rewrite it; pin it by hand", logger.Args("file:", workflow, "line:", ref.Line, "action:", update.action, "updated:", update.updated))
+			continue
+		}
+		if edit, ok := usesEdit(ref, update); ok {
+			edits = append(edits, edit)
+		}
+	}
+	if len(edits) == 0 && isActionFile(workflow) {
+		logger.Debug("Nothing to pin in action metadata file", logger.Args("file:", workflow))
+		return
+	}
+
 	pinnedWorkflow, err := createTempYAMLFile(workflow)
 	if err != nil {
 		logger.Warn("Error creating temp file", logger.Args("file:", workflow, "error:", err))
+		return
 	}
-
-	// Loop through all the jobs and steps
-	for _, job := range wf.Jobs {
-		for i, step := range job.Steps {
-			logger.Trace("Processing Step", logger.Args("step:", fmt.Sprintf("%d %s", i+1, step.Name)))
-			if action := step.Uses; action != "" {
-				pinActionInWorkflow(pinnedWorkflow, action)
-			}
-		}
+	if err := os.WriteFile(pinnedWorkflow, pkg.RewriteUses(content, edits), 0600); err != nil {
+		logger.Warn("Error writing file", logger.Args("file:", pinnedWorkflow, "error:", err))
+		return
 	}
 	if overwriteWorkflows {
 		err := os.Rename(pinnedWorkflow, workflow)
 		if err != nil {
 			logger.Warn("Error renaming file", logger.Args("file:", pinnedWorkflow, "error:", err))
+			return
 		}
 		pinnedWorkflow = workflow
 	}
-	if err == nil {
-		fmt.Println("Done! Please review the changes in the following file:", pinnedWorkflow)
+	fmt.Println("Done! Please review the changes in the following file:", pinnedWorkflow)
+}
+
+// usesEdit turns a resolved update into an edit of the step at ref. The "owner/repo@<sha> #<tag>"
+// form of update.updated is split into the new value and its version comment; re-pinned
+// actions replace the version marker of their existing comment, and container images drop
+// the docker:// prefix they were resolved with.
+func usesEdit(ref pkg.UsesRef, update actionUpdate) (pkg.UsesEdit, bool) {
+	if update.updated == "" {
+		return pkg.UsesEdit{}, false
+	}
+	value, comment, _ := strings.Cut(update.updated, " #")
+	if ref.Image {
+		value = strings.TrimPrefix(value, dockerPrefix)
 	}
+	if update.pinned {
+		logger.Info("Re-pinning action to latest", logger.Args("line:", ref.Line, "action:", update.action, "updated:", update.updated))
+	} else {
+		logger.Info("Replacing action with sha", logger.Args("line:", ref.Line, "action:", update.action, "sha:", update.updated))
+	}
+	return pkg.UsesEdit{Ref: ref, Value: value, Comment: comment, ReplaceComment: update.pinned}, true
+}
 
+// prefetchActions resolves the refs of many actions in batched GraphQL round-trips and puts
+// the batched resolver behind the resolution cache, so cached lookups never reach it and its
+// answers are cached like any other. Repositories whose tags are already cached aren't
+// prefetched. It only applies to the REST backend and when more than batchThreshold actions
+// need resolution; on failure every action is simply resolved individually.
+func prefetchActions(actions []string) {
+	if mirrorDir != "" || backend != backendREST {
+		return
+	}
+	refs := batchRefs(actions)
+	base := resolver
+	cache, caching := resolver.(*pkg.CachingResolver)
+	if caching {
+		base = cache.Backend()
+		refs = uncachedRefs(cache, refs)
+	}
+	if len(refs) == 0 {
+		return
+	}
+	batcher, err := pkg.NewGraphQLResolver(api.ClientOptions{Host: resolutionHost(), Transport: newRetryTransport()}, base)
+	if err != nil {
+		logger.Debug("Batched resolution unavailable", logger.Args("error:", err))
+		return
+	}
+	logger.Debug("Prefetching action refs with GraphQL", logger.Args("repositories", len(refs)))
+	if err := batcher.Prefetch(refs); err != nil {
+		logger.Warn("Batched GraphQL resolution failed; resolving actions individually", logger.Args("error:", err))
+	}
+	if caching {
+		resolver = cache.WithBackend(batcher)
+		return
+	}
+	resolver = batcher
 }
 
-// pinActionInWorkflow pins a single action reference in pinnedWorkflow. Already-hashed actions are
-// left untouched unless --latest is set, in which case they are re-pinned to the newest release;
-// version- or branch-tagged actions are resolved to their commit SHA.
-func pinActionInWorkflow(pinnedWorkflow string, action string) {
-	if hashRegexp.MatchString(action) {
-		if !pinLatest {
-			logger.Info("Action already has a hash", logger.Args("action:", action))
-			return
+// uncachedRefs drops the repositories whose tags the cache already holds.
+func uncachedRefs(cache *pkg.CachingResolver, refs []pkg.RepoRefs) []pkg.RepoRefs {
+	var uncached []pkg.RepoRefs
+	for _, ref := range refs {
+		if !cache.HasTags(ref.Repository) {
+			uncached = append(uncached, ref)
 		}
-		updated, changed, err := processPinnedActionToLatest(action)
+	}
+	return uncached
+}
+
+// batchRefs groups the refs that need resolving by repository. It returns nil when no more
+// than batchThreshold actions need resolution, where a batch isn't worth it.
+func batchRefs(actions []string) []pkg.RepoRefs {
+	var refs []pkg.RepoRefs
+	index := map[string]int{}
+	pending := 0
+	for _, action := range actions {
+		if strings.Contains(action, "./") || strings.HasPrefix(action, dockerPrefix) ||
+			(hashRegexp.MatchString(action) && !pinLatest) {
+			continue
+		}
+		repoWithOwner, ref, err := pkg.SplitActionString(action, "@")
 		if err != nil {
-			logger.Warn("Could not re-pin already-pinned action to latest; leaving as-is",
-				logger.Args("action:", action, "error:", err))
-			return
+			continue
 		}
-		if !changed {
-			logger.Info("Action already pinned to latest", logger.Args("action:", action))
-			return
+		pending++
+		repo := pkg.ExtractOwnerRepo(repoWithOwner)
+		i, ok := index[repo]
+		if !ok {
+			i = len(refs)
+			index[repo] = i
+			refs = append(refs, pkg.RepoRefs{Repository: repo})
 		}
-		writePinnedActionUpdate(pinnedWorkflow, action, updated)
-		return
+		if !hashRegexp.MatchString(action) && !strings.Contains(action, "@v") && isBranchRef(action) {
+			refs[i].Branches = append(refs[i].Branches, ref)
+		}
+	}
+	if pending <= batchThreshold {
+		return nil
+	}
+	return refs
+}
+
+// actionUpdate is the resolved rewrite of a single action reference. An empty updated
+// leaves the reference untouched.
+type actionUpdate struct {
+	action  string
+	updated string
+	// pinned marks a re-pin of an already SHA-pinned ref, whose trailing version comment is
+	// rewritten along with it.
+	pinned bool
+	// impostor marks a SHA-pinned ref whose commit no branch or tag of the repository reaches.
+	impostor bool
+	// unverified marks a SHA-pinned ref whose repository has too many refs to prove its commit
+	// reachable. --impostor-check=refuse treats it as an impostor.
+	unverified bool
+}
+
+// resolveActions resolves every action with a pool of concurrency workers and returns the
+// updates keyed by action reference.
+func resolveActions(actions []string, concurrency int) map[string]actionUpdate {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	updates := make(map[string]actionUpdate, len(actions))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for action := range jobs {
+				update := resolveAction(action)
+				mu.Lock()
+				updates[action] = update
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, action := range actions {
+		jobs <- action
+	}
+	close(jobs)
+	wg.Wait()
+	return updates
+}
+
+// resolveAction resolves a single action reference. Already-hashed actions are checked for
+// impostor commits and left untouched unless --latest is set, in which case they are re-pinned
+// to the newest release; version- or branch-tagged actions are resolved to their commit SHA.
+func resolveAction(action string) actionUpdate {
+	if hashRegexp.MatchString(action) {
+		update := resolvePinnedAction(action)
+		update.impostor, update.unverified = checkImpostorCommit(action)
+		return update
 	}
 	// Action doesn't have a hash
 	actionWithSha, err := processAction(action)
 	if err != nil {
-		logger.Warn("Nothing will be updated")
+		logger.Warn("Nothing will be updated", logger.Args("action:", action, "reason:", describeResolveError(err)))
+		return actionUpdate{action: action}
+	}
+	if actionWithSha == action {
+		return actionUpdate{action: action}
 	}
-	writeModifiedWorkflowToFile(pinnedWorkflow, action, actionWithSha)
+	return actionUpdate{action: action, updated: actionWithSha}
 }
 
-func writeModifiedWorkflowToFile(fileName string, action string, actionWithSha string) {
-	if action == "" || actionWithSha == "" {
-		logger.Error("action or actionWithSha are empty", logger.Args("action:", action, "actionWithSha:", actionWithSha))
+// resolvePinnedAction re-pins an already SHA-pinned action to the newest release when --latest
+// is set, and otherwise leaves it as is.
+func resolvePinnedAction(action string) actionUpdate {
+	if !pinLatest {
+		logger.Info("Action already has a hash", logger.Args("action:", action))
+		return actionUpdate{action: action}
 	}
-	newFileContent, err := os.ReadFile(fileName)
+	updated, changed, err := processPinnedActionToLatest(action)
 	if err != nil {
-		logger.Warn("Error reading file", logger.Args("file:", fileName, "error:", err))
+		logger.Warn("Could not re-pin already-pinned action to latest; leaving as-is",
+			logger.Args("action:", action, "error:", err))
+		return actionUpdate{action: action}
 	}
-	modifiedContent := strings.Replace(string(newFileContent), action, actionWithSha, 1)
-	err = os.WriteFile(fileName, []byte(modifiedContent), 0600)
+	if !changed {
+		logger.Info("Action already pinned to latest", logger.Args("action:", action))
+		return actionUpdate{action: action}
+	}
+	return actionUpdate{action: action, updated: updated, pinned: true}
+}
+
+// checkImpostorCommit reports whether a SHA-pinned action points at a commit that no branch or
+// tag of its repository reaches, such as a commit pushed to a fork and referenced through the
+// parent's path. unverified reports a commit that the compare limit left unproven either way.
+// Commits that can't be checked for other reasons are warned about but not reported.
+func checkImpostorCommit(action string) (impostor, unverified bool) {
+	if impostorCheck == impostorOff {
+		return false, false
+	}
+	repoWithOwner, sha, err := pkg.SplitActionString(action, "@")
 	if err != nil {
-		logger.Warn("Error creating file", logger.Args("file:", fileName, "error:", err))
+		return false, false
+	}
+	reachable, err := resolver.CommitReachable(pkg.ExtractOwnerRepo(repoWithOwner), sha)
+	if errors.Is(err, pkg.ErrUnverified) {
+		logger.Warn("Pinned commit is not reachable from the default branch or the first refs checked; the repository has too many refs to rule out an impostor commit",
+			logger.Args("action:", action))
+		return false, true
 	}
-	logger.Info("Replacing action with sha", logger.Args("action:", action, "sha:", actionWithSha))
+	if err != nil {
+		logger.Warn("Unable to check that the pinned commit belongs to the repository", logger.Args("action:", action, "reason:", describeResolveError(err)))
+		return false, false
+	}
+	if reachable {
+		return false, false
+	}
+	logger.Error("Pinned commit is not reachable from any branch or tag of the repository; it may be an impostor commit from a fork",
+		logger.Args("action:", action))
+	return true, false
+}
+
+// impostorActions returns the actions flagged as impostor commits or left unverified, in a
+// stable order.
+func impostorActions(updates map[string]actionUpdate) []string {
+	var impostors []string
+	for action, update := range updates {
+		if update.impostor || update.unverified {
+			impostors = append(impostors, action)
+		}
+	}
+	sort.Strings(impostors)
+	return impostors
 }
 
 func createTempYAMLFile(fileName string) (string, error) {
@@ -186,8 +547,11 @@ func createTempYAMLFile(fileName string) (string, error) {
 		logger.Warn("Error reading file", logger.Args("file:", fileName, "error:", err))
 		return "", err
 	}
-	tempFileName := strings.TrimSuffix(fileName, ".yml")
-	newFileName := tempFileName + "-pin.yml"
+	// Keep the extension so action.yaml becomes action-pin.yaml rather than action.yaml-pin.yml.
+	newFileName := strings.TrimSuffix(fileName, ".yml") + "-pin.yml"
+	if strings.HasSuffix(fileName, ".yaml") {
+		newFileName = strings.TrimSuffix(fileName, ".yaml") + "-pin.yaml"
+	}
 
 	err = os.WriteFile(newFileName, content, 0600)
 	if err != nil {
@@ -206,16 +570,46 @@ func selectVersion(declared string, pinLatest bool) string {
 	return declared
 }
 
+// parseConstraintFlags parses owner/repo=EXPR values into a map keyed by owner/repo,
+// validating each expression up front so a typo fails before any workflow is read.
+func parseConstraintFlags(values []string) (map[string]string, error) {
+	constraints := make(map[string]string, len(values))
+	for _, value := range values {
+		repo, expr, ok := strings.Cut(value, "=")
+		repo, expr = strings.TrimSpace(repo), strings.TrimSpace(expr)
+		if !ok || strings.Count(repo, "/") != 1 || expr == "" {
+			return nil, fmt.Errorf("%q must be in the format owner/repo=EXPR", value)
+		}
+		if _, err := pkg.ParseConstraint(expr); err != nil {
+			return nil, err
+		}
+		constraints[repo] = expr
+	}
+	return constraints, nil
+}
+
+// versionFor returns the version to resolve for an action: its --constraint when one is set
+// for the owner/repo, which also caps --latest, otherwise selectVersion of the declared version.
+func versionFor(repoWithOwner string, declared string) string {
+	if constraint, ok := actionConstraints[pkg.ExtractOwnerRepo(repoWithOwner)]; ok {
+		return constraint
+	}
+	return selectVersion(declared, pinLatest)
+}
+
 func processActionWithVersion(actionWithVersion string) (string, error) {
 	repoWithOwner, versionParsed, err := pkg.SplitActionString(actionWithVersion, "@v")
 	if err != nil {
 		return "", err
 	}
 	actionVersion := pkg.FormatVersion(versionParsed)
-	commitSha, tagVersion, err := GetActionHashByVersion(repoWithOwner, selectVersion(actionVersion, pinLatest))
+	commitSha, tagVersion, err := GetActionHashByVersion(repoWithOwner, versionFor(repoWithOwner, actionVersion))
 	if err != nil {
 		return "", err
 	}
+	if err := CheckSignature(repoWithOwner, commitSha, tagVersion); err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%s@%s #%s", repoWithOwner, strings.TrimSpace(commitSha), strings.TrimSpace(tagVersion)), nil
 
 }
@@ -229,6 +623,9 @@ func processActionWithBranch(actionWithBranch string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if err := CheckSignature(repoWithOwner, commitSha, branchName); err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%s@%s #%s", repoWithOwner, strings.TrimSpace(commitSha), strings.TrimSpace(branchName)), nil
 }
 
@@ -236,6 +633,13 @@ func processAction(action string) (string, error) {
 	var actionWithSha string
 	var err error
 	switch {
+	case strings.HasPrefix(action, dockerPrefix):
+		// Action is a container image
+		actionWithSha, err = processDockerAction(action)
+		if err != nil {
+			logger.Error("Error getting digest for image", logger.Args("action:", action, "error:", err))
+			return action, err
+		}
 	case strings.Contains(action, "@v"):
 		// Action has a version
 		actionWithVersion := action
@@ -245,8 +649,8 @@ func processAction(action string) (string, error) {
 			actionWithSha = actionWithVersion
 			return actionWithSha, err
 		}
-	case branchRegexp.MatchString(action):
-		// Action has a branch
+	case isBranchRef(action):
+		// Action or reusable workflow has a branch
 		actionWithBranch := action
 		actionWithSha, err = processActionWithBranch(actionWithBranch)
 		if err != nil {
@@ -262,6 +666,33 @@ func processAction(action string) (string, error) {
 	return actionWithSha, nil
 }
 
+// isBranchRef reports whether an action or reusable workflow reference that isn't a version
+// names a branch.
+func isBranchRef(action string) bool {
+	return branchRegexp.MatchString(action) || reusableWorkflowRegexp.MatchString(action)
+}
+
+// processDockerAction pins a docker:// image to the digest its tag points to, returning
+// "docker://<image>@sha256:<hex> #<tag>". Images already pinned to a digest are returned as is.
+func processDockerAction(action string) (string, error) {
+	image, err := pkg.ParseImageRef(strings.TrimPrefix(action, dockerPrefix))
+	if err != nil {
+		return "", err
+	}
+	if image.Digest != "" {
+		logger.Info("Image already has a digest", logger.Args("action:", action))
+		return action, nil
+	}
+	if images == nil {
+		return "", fmt.Errorf("container images can't be resolved offline with --mirror-dir")
+	}
+	digest, err := images.Digest(image)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s@%s #%s", dockerPrefix, image.Name, digest, image.Tag), nil
+}
+
 type latestResult struct {
 	sha string
 	tag string
@@ -269,15 +700,24 @@ type latestResult struct {
 }
 
 // latestCache memoizes "latest" lookups per run, keyed by owner/repo so sub-paths share a lookup.
-var latestCache = map[string]latestResult{}
+// It is shared by the resolution workers, so access goes through latestCacheMu.
+var (
+	latestCache   = map[string]latestResult{}
+	latestCacheMu sync.Mutex
+)
 
 func resolveLatest(repoWithOwner string) (string, string, error) {
 	key := pkg.ExtractOwnerRepo(repoWithOwner)
-	if r, ok := latestCache[key]; ok {
+	latestCacheMu.Lock()
+	r, ok := latestCache[key]
+	latestCacheMu.Unlock()
+	if ok {
 		return r.sha, r.tag, r.err
 	}
-	sha, tag, err := GetActionHashByVersion(repoWithOwner, latestVersion)
+	sha, tag, err := GetActionHashByVersion(repoWithOwner, versionFor(repoWithOwner, latestVersion))
+	latestCacheMu.Lock()
 	latestCache[key] = latestResult{sha: sha, tag: tag, err: err}
+	latestCacheMu.Unlock()
 	return sha, tag, err
 }
 
@@ -293,33 +733,11 @@ func processPinnedActionToLatest(action string) (string, bool, error) {
 	if err != nil {
 		return "", false, err
 	}
+	if err := CheckSignature(repoWithOwner, sha, tag); err != nil {
+		return "", false, err
+	}
 	resolvedSha := strings.TrimSpace(sha)
 	oldSha := strings.TrimPrefix(action, repoWithOwner+"@")
 	changed := resolvedSha != oldSha
 	return fmt.Sprintf("%s@%s #%s", repoWithOwner, resolvedSha, strings.TrimSpace(tag)), changed, nil
 }
-
-// writePinnedActionUpdate rewrites the first occurrence of a SHA-pinned ref (and its trailing
-// comment) in fileName with actionWithSha, warning without writing when the ref cannot be located.
-func writePinnedActionUpdate(fileName string, action string, actionWithSha string) {
-	if action == "" || actionWithSha == "" {
-		logger.Error("action or actionWithSha are empty", logger.Args("action:", action, "actionWithSha:", actionWithSha))
-		return
-	}
-	content, err := os.ReadFile(fileName)
-	if err != nil {
-		logger.Warn("Error reading file", logger.Args("file:", fileName, "error:", err))
-		return
-	}
-	modifiedContent, matched := pkg.ReplaceActionRef(string(content), action, actionWithSha)
-	if !matched {
-		logger.Warn("Resolved latest but could not locate pinned ref in file text; leaving unchanged",
-			logger.Args("file:", fileName, "action:", action))
-		return
-	}
-	if err := os.WriteFile(fileName, []byte(modifiedContent), 0600); err != nil {
-		logger.Warn("Error writing file", logger.Args("file:", fileName, "error:", err))
-		return
-	}
-	logger.Info("Re-pinning action to latest", logger.Args("action:", action, "updated:", actionWithSha))
-}
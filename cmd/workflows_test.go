@@ -1,16 +1,21 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/amenocal/gh-pin-actions/pkg"
 	"github.com/pterm/pterm"
 )
 
 func TestMain(m *testing.M) {
-	// writePinnedActionUpdate and friends log via the package-level logger.
+	// pinWorkflowFile and friends log via the package-level logger.
 	logger = pterm.DefaultLogger.WithLevel(pterm.LogLevelWarn)
 	// Guard against latestCache state leaking across the test binary's runs.
 	latestCache = map[string]latestResult{}
@@ -38,6 +43,83 @@ func TestSelectVersion(t *testing.T) {
 	}
 }
 
+func TestParseConstraintFlags(t *testing.T) {
+	got, err := parseConstraintFlags([]string{"actions/checkout=^4 !=4.0.1", "actions/setup-go = ~5.1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]string{"actions/checkout": "^4 !=4.0.1", "actions/setup-go": "~5.1"}
+	if len(got) != len(want) {
+		t.Fatalf("parseConstraintFlags() = %v, want %v", got, want)
+	}
+	for repo, expr := range want {
+		if got[repo] != expr {
+			t.Errorf("parseConstraintFlags()[%q] = %q, want %q", repo, got[repo], expr)
+		}
+	}
+
+	for _, value := range []string{"actions/checkout", "checkout=^4", "actions/checkout=", "actions/checkout=main"} {
+		if _, err := parseConstraintFlags([]string{value}); err == nil {
+			t.Errorf("parseConstraintFlags(%q) expected error, got nil", value)
+		}
+	}
+}
+
+func TestVersionFor(t *testing.T) {
+	actionConstraints = map[string]string{"actions/checkout": "4 !=4.2.2"}
+	defer func() { actionConstraints, pinLatest = nil, false }()
+
+	tests := []struct {
+		name      string
+		repo      string
+		declared  string
+		pinLatest bool
+		want      string
+	}{
+		{name: "constraint replaces declared", repo: "actions/checkout", declared: "3", want: "4 !=4.2.2"},
+		{name: "constraint applies to sub-path actions", repo: "actions/checkout/sub", declared: "3", want: "4 !=4.2.2"},
+		{name: "constraint caps latest", repo: "actions/checkout", declared: "latest", pinLatest: true, want: "4 !=4.2.2"},
+		{name: "declared without constraint", repo: "actions/setup-go", declared: "5", want: "5"},
+		{name: "latest without constraint", repo: "actions/setup-go", declared: "5", pinLatest: true, want: "latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinLatest = tt.pinLatest
+			if got := versionFor(tt.repo, tt.declared); got != tt.want {
+				t.Errorf("versionFor(%q, %q) = %q, want %q", tt.repo, tt.declared, got, tt.want)
+			}
+		})
+	}
+
+	resolver = newTestResolver()
+	pinLatest = false
+	got, err := processActionWithVersion("actions/checkout@v3")
+	if err != nil {
+		t.Fatalf("processActionWithVersion unexpected error: %v", err)
+	}
+	if want := "actions/checkout@" + testShaB + " #v4.1.7"; got != want {
+		t.Errorf("processActionWithVersion with constraint = %q, want %q", got, want)
+	}
+}
+
+func TestProcessPinnedActionToLatestPrereleasePolicy(t *testing.T) {
+	resolver = newTestResolver()
+	prereleasePolicy = pkg.PrereleaseInclude
+	latestCache = map[string]latestResult{}
+	defer func() {
+		prereleasePolicy = pkg.PrereleaseExclude
+		latestCache = map[string]latestResult{}
+	}()
+
+	got, changed, err := processPinnedActionToLatest("actions/checkout@" + testShaA)
+	if err != nil {
+		t.Fatalf("processPinnedActionToLatest unexpected error: %v", err)
+	}
+	if want := "actions/checkout@" + testShaD + " #v5.0.0-beta.1"; got != want || !changed {
+		t.Errorf("processPinnedActionToLatest with include = (%q, %v), want (%q, true)", got, changed, want)
+	}
+}
+
 func TestGetWorkflowFiles(t *testing.T) {
 	// Setup: create a temporary directory with test files
 	tempDir := t.TempDir()
@@ -102,6 +184,152 @@ func TestGetWorkflowFiles(t *testing.T) {
 	}
 }
 
+func TestGetPinnableFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, file := range []string{
+		filepath.Join(".github", "workflows", "ci.yml"),
+		filepath.Join(".github", "actions", "setup", "action.yml"),
+		filepath.Join(".github", "actions", "lint", "action.yaml"),
+		filepath.Join(".github", "actions", "lint", "action-pin.yaml"),
+		filepath.Join(".github", "actions", "js", "node_modules", "dep", "action.yml"),
+		filepath.Join(".github", "workflows", "action.yml"),
+		filepath.Join("node_modules", "dep", "action.yml"),
+		filepath.Join("vendor", "org", "dep", "action.yml"),
+		filepath.Join("testdata", "fixture", "action.yml"),
+		filepath.Join(".git", "action.yml"),
+		"action.yml",
+	} {
+		path := filepath.Join(tempDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Unexpected error creating directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("name: test\n"), 0644); err != nil {
+			t.Fatalf("Unexpected error writing %s: %v", file, err)
+		}
+	}
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error getting working directory: %v", err)
+	}
+	defer os.Chdir(originalDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Unexpected error changing directory: %v", err)
+	}
+
+	files, err := getPinnableFiles()
+	if err != nil {
+		t.Fatalf("getPinnableFiles unexpected error: %v", err)
+	}
+	want := []string{
+		filepath.Join(".github", "workflows", "action.yml"),
+		filepath.Join(".github", "workflows", "ci.yml"),
+		filepath.Join(".github", "actions", "lint", "action.yaml"),
+		filepath.Join(".github", "actions", "setup", "action.yml"),
+		"action.yml",
+	}
+	if fmt.Sprint(files) != fmt.Sprint(want) {
+		t.Errorf("getPinnableFiles = %v, want %v", files, want)
+	}
+
+	// An action repository without workflows still has files to pin.
+	if err := os.RemoveAll(".github"); err != nil {
+		t.Fatalf("Unexpected error removing .github: %v", err)
+	}
+	if files, err := getPinnableFiles(); err != nil || fmt.Sprint(files) != "[action.yml]" {
+		t.Errorf("getPinnableFiles without workflows = %v, %v, want [action.yml]", files, err)
+	}
+	if err := os.Remove("action.yml"); err != nil {
+		t.Fatalf("Unexpected error removing action.yml: %v", err)
+	}
+	if _, err := getPinnableFiles(); err == nil {
+		t.Errorf("Expected error without workflows or actions")
+	}
+}
+
+func TestGetActionFilesUnreadableDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced for root")
+	}
+	tempDir := t.TempDir()
+	for _, file := range []string{
+		filepath.Join(".github", "actions", "private", "action.yml"),
+		filepath.Join(".github", "actions", "setup", "action.yml"),
+	} {
+		path := filepath.Join(tempDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Unexpected error creating directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("name: test\n"), 0644); err != nil {
+			t.Fatalf("Unexpected error writing %s: %v", file, err)
+		}
+	}
+	private := filepath.Join(tempDir, ".github", "actions", "private")
+	if err := os.Chmod(private, 0); err != nil {
+		t.Fatalf("Unexpected error changing permissions: %v", err)
+	}
+	defer os.Chmod(private, 0755)
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected error getting working directory: %v", err)
+	}
+	defer os.Chdir(originalDir)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Unexpected error changing directory: %v", err)
+	}
+
+	want := []string{filepath.Join(".github", "actions", "setup", "action.yml")}
+	if files := getActionFiles(); fmt.Sprint(files) != fmt.Sprint(want) {
+		t.Errorf("getActionFiles = %v, want %v", files, want)
+	}
+}
+
+func TestPinCompositeAction(t *testing.T) {
+	resolver = newTestResolver()
+	action := filepath.Join(t.TempDir(), "action.yaml")
+	initial := "name: setup\n" +
+		"runs:\n" +
+		"  using: composite\n" +
+		"  steps:\n" +
+		"    - uses: actions/checkout@v4\n" +
+		"    - uses: ./.github/actions/other\n"
+	if err := os.WriteFile(action, []byte(initial), 0644); err != nil {
+		t.Fatalf("Unexpected error writing action: %v", err)
+	}
+	actions, err := readWorkflowActions(action)
+	if err != nil {
+		t.Fatalf("readWorkflowActions unexpected error: %v", err)
+	}
+
+	pinWorkflowFile(action, resolveActions(actions, 1))
+
+	got, err := os.ReadFile(strings.TrimSuffix(action, ".yaml") + "-pin.yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error reading pinned action: %v", err)
+	}
+	want := "name: setup\n" +
+		"runs:\n" +
+		"  using: composite\n" +
+		"  steps:\n" +
+		"    - uses: actions/checkout@" + testShaA + " #v4.2.2\n" +
+		"    - uses: ./.github/actions/other\n"
+	if string(got) != want {
+		t.Errorf("pinned action = %q, want %q", string(got), want)
+	}
+}
+
+func TestPinActionWithoutUpdates(t *testing.T) {
+	resolver = newTestResolver()
+	dir := t.TempDir()
+	action := filepath.Join(dir, "action.yml")
+	if err := os.WriteFile(action, []byte("name: node\nruns:\n  using: node20\n  main: dist/index.js\n"), 0644); err != nil {
+		t.Fatalf("Unexpected error writing action: %v", err)
+	}
+	pinWorkflowFile(action, map[string]actionUpdate{})
+	if _, err := os.Stat(filepath.Join(dir, "action-pin.yml")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("pinWorkflowFile wrote a copy of an action with nothing to pin (stat error %v)", err)
+	}
+}
+
 func TestCreateTempYAMLFile(t *testing.T) {
 	// Setup: create a temporary file
 	tempFile, err := os.CreateTemp("", "tempfile")
@@ -135,32 +363,53 @@ func TestCreateTempYAMLFile(t *testing.T) {
 	}
 }
 
-func TestWritePinnedActionUpdate(t *testing.T) {
+func TestPinWorkflowFileUpdates(t *testing.T) {
 	const (
 		shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
 		shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
 		shaX = "cccccccccccccccccccccccccccccccccccccccc"
 	)
 	tests := []struct {
-		name          string
-		initial       string
-		action        string
-		actionWithSha string
-		want          string
+		name    string
+		initial string
+		update  actionUpdate
+		want    string
 	}{
 		{
-			name:          "rewrites matching ref in file",
-			initial:       "      - uses: actions/checkout@" + shaA + " # v4.1.1\n",
-			action:        "actions/checkout@" + shaA,
-			actionWithSha: "actions/checkout@" + shaB + " #v4.2.2",
-			want:          "      - uses: actions/checkout@" + shaB + " #v4.2.2\n",
+			name:    "re-pins matching ref and its comment",
+			initial: "jobs:\n  build:\n    steps:\n      - uses: actions/checkout@" + shaA + " # v4.1.1\n",
+			update:  actionUpdate{action: "actions/checkout@" + shaA, updated: "actions/checkout@" + shaB + " #v4.2.2", pinned: true},
+			want:    "jobs:\n  build:\n    steps:\n      - uses: actions/checkout@" + shaB + " #v4.2.2\n",
+		},
+		{
+			name:    "no match leaves file unchanged",
+			initial: "jobs:\n  build:\n    steps:\n      - uses: \"actions/checkout@" + shaA + "\"\n",
+			update:  actionUpdate{action: "actions/missing@" + shaX, updated: "actions/missing@" + shaB + " #v1.0.0", pinned: true},
+			want:    "jobs:\n  build:\n    steps:\n      - uses: \"actions/checkout@" + shaA + "\"\n",
 		},
 		{
-			name:          "no match leaves file unchanged",
-			initial:       "      - uses: \"actions/checkout@" + shaA + "\"\n",
-			action:        "actions/missing@" + shaX,
-			actionWithSha: "actions/missing@" + shaB + " #v1.0.0",
-			want:          "      - uses: \"actions/checkout@" + shaA + "\"\n",
+			name: "mentions outside uses are left alone",
+			initial: "# actions/checkout@v4 is pinned below\n" +
+				"jobs:\n" +
+				"  build:\n" +
+				"    steps:\n" +
+				"      - name: actions/checkout@v4\n" +
+				"        run: echo actions/checkout@v4\n" +
+				"      - uses: 'actions/checkout@v4'\n",
+			update: actionUpdate{action: "actions/checkout@v4", updated: "actions/checkout@" + shaA + " #v4.2.2"},
+			want: "# actions/checkout@v4 is pinned below\n" +
+				"jobs:\n" +
+				"  build:\n" +
+				"    steps:\n" +
+				"      - name: actions/checkout@v4\n" +
+				"        run: echo actions/checkout@v4\n" +
+				"      - uses: 'actions/checkout@" + shaA + "' #v4.2.2\n",
+		},
+		{
+			name:    "block scalar is left for the others to be pinned",
+			initial: "jobs:\n  build:\n    steps:\n      - uses: >-\n          actions/checkout@v4\n      - uses: actions/checkout@v4\n",
+			update:  actionUpdate{action: "actions/checkout@v4", updated: "actions/checkout@" + shaA + " #v4.2.2"},
+			want:    "jobs:\n  build:\n    steps:\n      - uses: >-\n          actions/checkout@v4\n      - uses: actions/checkout@" + shaA + " #v4.2.2\n",
 		},
 	}
 	for _, tt := range tests {
@@ -169,14 +418,326 @@ func TestWritePinnedActionUpdate(t *testing.T) {
 			if err := os.WriteFile(file, []byte(tt.initial), 0644); err != nil {
 				t.Fatalf("Unexpected error writing initial file: %v", err)
 			}
-			writePinnedActionUpdate(file, tt.action, tt.actionWithSha)
-			got, err := os.ReadFile(file)
+			pinWorkflowFile(file, map[string]actionUpdate{tt.update.action: tt.update})
+			got, err := os.ReadFile(strings.TrimSuffix(file, ".yml") + "-pin.yml")
 			if err != nil {
 				t.Fatalf("Unexpected error reading file: %v", err)
 			}
 			if string(got) != tt.want {
-				t.Errorf("writePinnedActionUpdate result = %q, want %q", string(got), tt.want)
+				t.Errorf("pinWorkflowFile result = %q, want %q", string(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessAction(t *testing.T) {
+	resolver = newTestResolver()
+	tests := []struct {
+		name    string
+		action  string
+		want    string
+		wantErr bool
+	}{
+		{name: "version tag", action: "actions/checkout@v4", want: "actions/checkout@" + testShaA + " #v4.2.2"},
+		{name: "branch", action: "actions/checkout@main", want: "actions/checkout@" + testShaD + " #main"},
+		{name: "local action", action: "./.github/actions/setup", want: "./.github/actions/setup"},
+		{name: "reusable workflow version", action: "actions/checkout/.github/workflows/build.yml@v4", want: "actions/checkout/.github/workflows/build.yml@" + testShaA + " #v4.2.2"},
+		{name: "reusable workflow branch", action: "actions/checkout/.github/workflows/build.yaml@main", want: "actions/checkout/.github/workflows/build.yaml@" + testShaD + " #main"},
+		{name: "local reusable workflow", action: "./.github/workflows/build.yml", want: "./.github/workflows/build.yml"},
+		{name: "unknown branch returns input", action: "actions/checkout@missing", want: "actions/checkout@missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := processAction(tt.action)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processAction(%q) error = %v, wantErr %v", tt.action, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("processAction(%q) = %q, want %q", tt.action, got, tt.want)
 			}
 		})
 	}
 }
+
+func TestProcessDockerAction(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	images = &pkg.MemoryRegistry{Digests: map[string]string{"ghcr.io/org/tool:1.4": digest, "alpine:latest": digest}}
+	defer func() { images = nil }()
+
+	tests := []struct {
+		name    string
+		action  string
+		want    string
+		wantErr bool
+	}{
+		{name: "tag", action: "docker://ghcr.io/org/tool:1.4", want: "docker://ghcr.io/org/tool@" + digest + " #1.4"},
+		{name: "implicit latest", action: "docker://alpine", want: "docker://alpine@" + digest + " #latest"},
+		{name: "already pinned", action: "docker://alpine@" + digest, want: "docker://alpine@" + digest},
+		{name: "unknown tag returns input", action: "docker://ghcr.io/org/tool:9.9", want: "docker://ghcr.io/org/tool:9.9", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := processAction(tt.action)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processAction(%q) error = %v, wantErr %v", tt.action, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("processAction(%q) = %q, want %q", tt.action, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinWorkflowFileImages(t *testing.T) {
+	const digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	resolver = newTestResolver()
+	images = &pkg.MemoryRegistry{Digests: map[string]string{
+		"node:20":              digest,
+		"redis:7":              digest,
+		"ghcr.io/org/tool:1.4": digest,
+	}}
+	defer func() { images = nil }()
+
+	workflow := filepath.Join(t.TempDir(), "ci.yml")
+	initial := "jobs:\n" +
+		"  build:\n" +
+		"    container: node:20\n" +
+		"    services:\n" +
+		"      redis:\n" +
+		"        image: \"redis:7\"\n" +
+		"    steps:\n" +
+		"      - uses: docker://ghcr.io/org/tool:1.4\n" +
+		"      - uses: docker://node:20\n"
+	if err := os.WriteFile(workflow, []byte(initial), 0644); err != nil {
+		t.Fatalf("Unexpected error writing workflow: %v", err)
+	}
+	actions, err := readWorkflowActions(workflow)
+	if err != nil {
+		t.Fatalf("readWorkflowActions unexpected error: %v", err)
+	}
+
+	pinWorkflowFile(workflow, resolveActions(actions, 2))
+
+	got, err := os.ReadFile(strings.TrimSuffix(workflow, ".yml") + "-pin.yml")
+	if err != nil {
+		t.Fatalf("Unexpected error reading pinned workflow: %v", err)
+	}
+	want := "jobs:\n" +
+		"  build:\n" +
+		"    container: node@" + digest + " #20\n" +
+		"    services:\n" +
+		"      redis:\n" +
+		"        image: \"redis@" + digest + "\" #7\n" +
+		"    steps:\n" +
+		"      - uses: docker://ghcr.io/org/tool@" + digest + " #1.4\n" +
+		"      - uses: docker://node@" + digest + " #20\n"
+	if string(got) != want {
+		t.Errorf("pinned workflow = %q, want %q", string(got), want)
+	}
+}
+
+func TestResolveActions(t *testing.T) {
+	resolver = newTestResolver()
+	actions := []string{
+		"actions/checkout@v4",
+		"actions/checkout@v3",
+		"actions/checkout@main",
+		"actions/checkout@" + testShaB,
+		"./.github/actions/setup",
+		"actions/checkout@missing",
+	}
+	want := map[string]string{
+		"actions/checkout@v4":   "actions/checkout@" + testShaA + " #v4.2.2",
+		"actions/checkout@v3":   "actions/checkout@" + testShaC + " #v3.6.0",
+		"actions/checkout@main": "actions/checkout@" + testShaD + " #main",
+	}
+	for _, concurrency := range []int{0, 1, 4} {
+		updates := resolveActions(actions, concurrency)
+		if len(updates) != len(actions) {
+			t.Errorf("resolveActions(concurrency=%d) returned %d updates, want %d", concurrency, len(updates), len(actions))
+		}
+		for _, action := range actions {
+			if got := updates[action].updated; got != want[action] {
+				t.Errorf("resolveActions(concurrency=%d)[%q] = %q, want %q", concurrency, action, got, want[action])
+			}
+		}
+	}
+}
+
+func TestImpostorCommits(t *testing.T) {
+	const (
+		historySha  = "1111111111111111111111111111111111111111"
+		impostorSha = "2222222222222222222222222222222222222222"
+	)
+	r := newTestResolver()
+	r.History = map[string][]string{"actions/checkout": {historySha}}
+	resolver = r
+	defer func() { impostorCheck = impostorWarn }()
+
+	actions := []string{
+		"actions/checkout@" + testShaB,
+		"actions/checkout/sub@" + historySha,
+		"actions/checkout@" + impostorSha,
+		"actions/checkout@v4",
+	}
+	impostorCheck = impostorWarn
+	updates := resolveActions(actions, 2)
+	if got, want := impostorActions(updates), []string{"actions/checkout@" + impostorSha}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("impostorActions = %v, want %v", got, want)
+	}
+	if updates["actions/checkout@"+impostorSha].updated != "" {
+		t.Errorf("impostor pin was rewritten to %q, want it left as is", updates["actions/checkout@"+impostorSha].updated)
+	}
+
+	// An impostor in a repository with more refs than the compare limit can't be ruled out, so
+	// it is reported as unverified rather than passed.
+	for i := 0; i < 60; i++ {
+		r.Tags["actions/checkout"] = append(r.Tags["actions/checkout"], pkg.Tag{Name: fmt.Sprintf("v1.0.%d", i), SHA: testShaC})
+	}
+	updates = resolveActions(actions, 2)
+	if got, want := impostorActions(updates), []string{"actions/checkout@" + impostorSha}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("impostorActions with %d tags = %v, want %v", len(r.Tags["actions/checkout"]), got, want)
+	}
+	if update := updates["actions/checkout@"+impostorSha]; !update.unverified || update.impostor {
+		t.Errorf("impostor pin with %d tags = %+v, want it unverified", len(r.Tags["actions/checkout"]), update)
+	}
+	if update := updates["actions/checkout/sub@"+historySha]; update.unverified || update.impostor {
+		t.Errorf("reachable pin with %d tags = %+v, want it passed", len(r.Tags["actions/checkout"]), update)
+	}
+
+	impostorCheck = impostorOff
+	if impostor, unverified := checkImpostorCommit("actions/checkout@" + impostorSha); impostor || unverified {
+		t.Errorf("checkImpostorCommit with --impostor-check=off = (%v, %v), want (false, false)", impostor, unverified)
+	}
+}
+
+func TestProcessActionRequireSigned(t *testing.T) {
+	r := newTestResolver()
+	r.Verifications = map[string]pkg.Verification{testShaA: {Verified: true, Reason: "valid"}}
+	resolver = r
+	requireSigned = true
+	defer func() { requireSigned = false }()
+
+	if got, err := processAction("actions/checkout@v4"); err != nil || got != "actions/checkout@"+testShaA+" #v4.2.2" {
+		t.Errorf("processAction with a signed release = (%q, %v), want the pinned ref", got, err)
+	}
+	// Unsigned refs are left as they are.
+	for _, action := range []string{"actions/checkout@v3", "actions/checkout@main"} {
+		if got, err := processAction(action); err == nil || got != action {
+			t.Errorf("processAction(%q) with an unsigned commit = (%q, %v), want the input and an error", action, got, err)
+		}
+	}
+}
+
+func TestPinWorkflowFile(t *testing.T) {
+	resolver = newTestResolver()
+	workflow := filepath.Join(t.TempDir(), "ci.yml")
+	initial := "jobs:\n" +
+		"  build:\n" +
+		"    steps:\n" +
+		"      - uses: actions/checkout@v4\n" +
+		"      - uses: ./.github/actions/setup\n" +
+		"      - uses: actions/checkout@v4\n" +
+		"  release:\n" +
+		"    uses: actions/checkout/.github/workflows/release.yml@main\n" +
+		"  local:\n" +
+		"    uses: ./.github/workflows/local.yml\n"
+	if err := os.WriteFile(workflow, []byte(initial), 0644); err != nil {
+		t.Fatalf("Unexpected error writing workflow: %v", err)
+	}
+	actions, err := readWorkflowActions(workflow)
+	if err != nil {
+		t.Fatalf("readWorkflowActions unexpected error: %v", err)
+	}
+	if len(actions) != 5 {
+		t.Fatalf("readWorkflowActions returned %d actions, want 5", len(actions))
+	}
+
+	pinWorkflowFile(workflow, resolveActions(actions, 2))
+
+	got, err := os.ReadFile(strings.TrimSuffix(workflow, ".yml") + "-pin.yml")
+	if err != nil {
+		t.Fatalf("Unexpected error reading pinned workflow: %v", err)
+	}
+	want := "jobs:\n" +
+		"  build:\n" +
+		"    steps:\n" +
+		"      - uses: actions/checkout@" + testShaA + " #v4.2.2\n" +
+		"      - uses: ./.github/actions/setup\n" +
+		"      - uses: actions/checkout@" + testShaA + " #v4.2.2\n" +
+		"  release:\n" +
+		"    uses: actions/checkout/.github/workflows/release.yml@" + testShaD + " #main\n" +
+		"  local:\n" +
+		"    uses: ./.github/workflows/local.yml\n"
+	if string(got) != want {
+		t.Errorf("pinned workflow = %q, want %q", string(got), want)
+	}
+}
+
+func TestBatchRefs(t *testing.T) {
+	defer func() { pinLatest = false }()
+	actions := []string{
+		"actions/checkout@v4",
+		"actions/checkout@main",
+		"actions/setup-go@v5",
+		"actions/cache/restore@v4",
+		"actions/cache/save@v4",
+		"./.github/actions/local",
+		"docker://alpine:3.20",
+		"actions/upload-artifact@" + testShaA,
+	}
+	if refs := batchRefs(actions); refs != nil {
+		t.Errorf("batchRefs below threshold = %v, want nil", refs)
+	}
+
+	pinLatest = true
+	refs := batchRefs(append(actions, "github/codeql-action/init@v3"))
+	want := []pkg.RepoRefs{
+		{Repository: "actions/checkout", Branches: []string{"main"}},
+		{Repository: "actions/setup-go"},
+		{Repository: "actions/cache"},
+		{Repository: "actions/upload-artifact"},
+		{Repository: "github/codeql-action"},
+	}
+	if fmt.Sprint(refs) != fmt.Sprint(want) {
+		t.Errorf("batchRefs = %v, want %v", refs, want)
+	}
+}
+
+func TestPrefetchActionsCached(t *testing.T) {
+	actions := []string{
+		"actions/checkout@v4",
+		"actions/setup-go@v5",
+		"actions/cache@v4",
+		"actions/upload-artifact@v4",
+		"actions/download-artifact@v4",
+		"github/codeql-action/init@v3",
+	}
+	memory := &pkg.MemoryResolver{Tags: map[string][]pkg.Tag{}}
+	for _, ref := range batchRefs(actions) {
+		memory.Tags[ref.Repository] = []pkg.Tag{{Name: "v1.0.0", SHA: testShaA}}
+	}
+	cache := pkg.NewCachingResolver(memory, t.TempDir(), "rest@github.com", time.Hour)
+	if _, err := cache.ListTags("actions/checkout"); err != nil {
+		t.Fatalf("ListTags unexpected error: %v", err)
+	}
+	want := []pkg.RepoRefs{{Repository: "actions/setup-go"}, {Repository: "actions/cache"}, {Repository: "actions/upload-artifact"}, {Repository: "actions/download-artifact"}, {Repository: "github/codeql-action"}}
+	if got := uncachedRefs(cache, batchRefs(actions)); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("uncachedRefs = %v, want %v", got, want)
+	}
+
+	// With every repository cached there is nothing to prefetch and the cache stays in front.
+	for _, ref := range want {
+		if _, err := cache.ListTags(ref.Repository); err != nil {
+			t.Fatalf("ListTags unexpected error: %v", err)
+		}
+	}
+	prevBackend := backend
+	backend = backendREST
+	defer func() { backend = prevBackend }()
+	resolver = cache
+	prefetchActions(actions)
+	if resolver != pkg.Resolver(cache) {
+		t.Errorf("prefetchActions with a warm cache replaced the resolver with %T", resolver)
+	}
+}
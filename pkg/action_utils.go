@@ -2,7 +2,6 @@ package pkg
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 )
 
@@ -36,17 +35,16 @@ func RepoFromPinnedRef(action string) (string, error) {
 	return repoWithOwner, nil
 }
 
-// ReplaceActionRef replaces the first occurrence of a SHA-pinned action ref (and its optional
-// trailing version comment) in content with replacement, returning the updated content and whether a
-// match was found. Splice replacement avoids regexp `$` expansion and guarantees exactly one
-// occurrence is replaced. The optional `[ \t]+#[ \t]*\S+` group consumes only the stale version
-// marker (e.g. `# v4.1.1`), leaving any subsequent user comment (e.g. ` # keep`) intact, and is
-// CRLF/tab-safe so it doesn't disturb line endings.
-func ReplaceActionRef(content, action, replacement string) (string, bool) {
-	re := regexp.MustCompile(regexp.QuoteMeta(action) + `([ \t]+#[ \t]*\S+)?`)
-	loc := re.FindStringIndex(content)
-	if loc == nil {
-		return content, false
-	}
-	return content[:loc[0]] + replacement + content[loc[1]:], true
+// PinnedRef is a SHA-pinned action reference found in a workflow file, along with the version
+// marker of its trailing comment.
+type PinnedRef struct {
+	// Line is the 1-based line the reference is on.
+	Line int
+	// Action is the reference itself, owner/repo(/sub)@<sha>.
+	Action     string
+	Repository string
+	SHA        string
+	// Comment is the first word of the trailing comment without the "#" (e.g. "v4.1.1"), or
+	// empty when the reference has no comment.
+	Comment string
 }
@@ -82,97 +82,3 @@ func TestRepoFromPinnedRef(t *testing.T) {
 		})
 	}
 }
-
-func TestReplaceActionRef(t *testing.T) {
-	const (
-		shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
-		shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
-		shaX = "cccccccccccccccccccccccccccccccccccccccc"
-	)
-	tests := []struct {
-		name        string
-		content     string
-		action      string
-		replacement string
-		want        string
-		wantMatched bool
-	}{
-		{
-			name:        "ref with trailing comment",
-			content:     "      - uses: actions/checkout@" + shaA + " # v4.1.1\n",
-			action:      "actions/checkout@" + shaA,
-			replacement: "actions/checkout@" + shaB + " #v4.2.2",
-			want:        "      - uses: actions/checkout@" + shaB + " #v4.2.2\n",
-			wantMatched: true,
-		},
-		{
-			name:        "ref without comment",
-			content:     "      - uses: actions/checkout@" + shaA + "\n",
-			action:      "actions/checkout@" + shaA,
-			replacement: "actions/checkout@" + shaB + " #v4.2.2",
-			want:        "      - uses: actions/checkout@" + shaB + " #v4.2.2\n",
-			wantMatched: true,
-		},
-		{
-			name:        "sub-path action",
-			content:     "      - uses: docker/build-push-action/sub@" + shaA + " # v5.0.0\n",
-			action:      "docker/build-push-action/sub@" + shaA,
-			replacement: "docker/build-push-action/sub@" + shaB + " #v5.1.0",
-			want:        "      - uses: docker/build-push-action/sub@" + shaB + " #v5.1.0\n",
-			wantMatched: true,
-		},
-		{
-			name:        "CRLF line preserves carriage return",
-			content:     "      - uses: actions/checkout@" + shaA + " # v4.1.1\r\n",
-			action:      "actions/checkout@" + shaA,
-			replacement: "actions/checkout@" + shaB + " #v4.2.2",
-			want:        "      - uses: actions/checkout@" + shaB + " #v4.2.2\r\n",
-			wantMatched: true,
-		},
-		{
-			name: "duplicate refs only first rewritten",
-			content: "      - uses: actions/checkout@" + shaA + " # v4.1.1\n" +
-				"      - uses: actions/checkout@" + shaA + " # v4.1.1\n",
-			action:      "actions/checkout@" + shaA,
-			replacement: "actions/checkout@" + shaB + " #v4.2.2",
-			want: "      - uses: actions/checkout@" + shaB + " #v4.2.2\n" +
-				"      - uses: actions/checkout@" + shaA + " # v4.1.1\n",
-			wantMatched: true,
-		},
-		{
-			name:        "comment with extra hash preserves trailing note",
-			content:     "      - uses: actions/checkout@" + shaA + " # v4.1.1 # keep\n",
-			action:      "actions/checkout@" + shaA,
-			replacement: "actions/checkout@" + shaB + " #v4.2.2",
-			want:        "      - uses: actions/checkout@" + shaB + " #v4.2.2 # keep\n",
-			wantMatched: true,
-		},
-		{
-			name:        "no match leaves content unchanged",
-			content:     "      - uses: \"actions/checkout@" + shaA + "\"\n",
-			action:      "actions/missing@" + shaX,
-			replacement: "actions/missing@" + shaB + " #v1.0.0",
-			want:        "      - uses: \"actions/checkout@" + shaA + "\"\n",
-			wantMatched: false,
-		},
-		{
-			name:        "idempotent identical replacement",
-			content:     "      - uses: actions/checkout@" + shaB + " #v4.2.2\n",
-			action:      "actions/checkout@" + shaB,
-			replacement: "actions/checkout@" + shaB + " #v4.2.2",
-			want:        "      - uses: actions/checkout@" + shaB + " #v4.2.2\n",
-			wantMatched: true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, matched := ReplaceActionRef(tt.content, tt.action, tt.replacement)
-			if matched != tt.wantMatched {
-				t.Errorf("ReplaceActionRef matched = %v, want %v", matched, tt.wantMatched)
-			}
-			if got != tt.want {
-				t.Errorf("ReplaceActionRef result = %q, want %q", got, tt.want)
-			}
-		})
-	}
-}
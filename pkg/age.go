@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var ageRegexp = regexp.MustCompile(`^(\d+)([dw])$`)
+
+// dateLayouts are the layouts ParseDate accepts, most specific first.
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseAge parses an age such as "7d" or "2w", or any time.ParseDuration value such as "36h".
+// An empty age is zero.
+func ParseAge(age string) (time.Duration, error) {
+	if age == "" {
+		return 0, nil
+	}
+	if m := ageRegexp.FindStringSubmatch(age); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q: %w", age, err)
+		}
+		unit := 24 * time.Hour
+		if m[2] == "w" {
+			unit *= 7
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(age)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q: use a number of days (7d), weeks (2w) or a duration such as 36h", age)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid age %q: must not be negative", age)
+	}
+	return d, nil
+}
+
+// ParseDate parses an RFC 3339 timestamp or a date such as "2026-03-01", which is midnight
+// at the start of that day. Timestamps without a zone are read as UTC.
+func ParseDate(date string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or an RFC 3339 timestamp such as 2026-03-01T12:00:00Z", date)
+}
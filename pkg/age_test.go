@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		age     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{age: "", want: 0},
+		{age: "7d", want: 7 * 24 * time.Hour},
+		{age: "2w", want: 14 * 24 * time.Hour},
+		{age: "36h", want: 36 * time.Hour},
+		{age: "90m", want: 90 * time.Minute},
+		{age: "0d", want: 0},
+		{age: "-1h", wantErr: true},
+		{age: "7days", wantErr: true},
+		{age: "d", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseAge(test.age)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("Unexpected error for age %q: %v", test.age, err)
+		case err == nil && test.wantErr:
+			t.Errorf("Expected error for age %q", test.age)
+		case got != test.want:
+			t.Errorf("ParseAge(%q) = %v, want %v", test.age, got, test.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		date    string
+		want    time.Time
+		wantErr bool
+	}{
+		{date: "2026-03-01", want: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{date: "2026-03-01T12:30:00Z", want: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{date: "2026-03-01T12:30:00+02:00", want: time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{date: "2026-03-01 12:30", want: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{date: "03/01/2026", wantErr: true},
+		{date: "yesterday", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseDate(test.date)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("Unexpected error for date %q: %v", test.date, err)
+		case err == nil && test.wantErr:
+			t.Errorf("Expected error for date %q", test.date)
+		case !got.Equal(test.want):
+			t.Errorf("ParseDate(%q) = %v, want %v", test.date, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCacheTTL is how long a cached resolution stays valid unless overridden.
+const DefaultCacheTTL = 24 * time.Hour
+
+// CachingResolver wraps a Resolver with a persistent on-disk cache so repeated runs (or runs
+// across many repositories) don't query the same refs again until the TTL expires. Only
+// successful lookups are cached. Entries are written atomically, so concurrent lookups and
+// concurrent processes can share a cache directory.
+type CachingResolver struct {
+	resolver Resolver
+	dir      string
+	scope    string
+	ttl      time.Duration
+	now      func() time.Time
+	// commitsOnly passes lookups of refs, which can move, straight to resolver and only caches
+	// lookups of a fixed commit.
+	commitsOnly bool
+}
+
+// cacheEntry is the on-disk format of one cached lookup.
+type cacheEntry struct {
+	Key      string          `json:"key"`
+	StoredAt time.Time       `json:"stored_at"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// NewCachingResolver caches the lookups of resolver under dir for ttl. scope is folded into
+// every key so entries from different backends or hosts never collide.
+func NewCachingResolver(resolver Resolver, dir string, scope string, ttl time.Duration) *CachingResolver {
+	return &CachingResolver{resolver: resolver, dir: dir, scope: scope, ttl: ttl, now: time.Now}
+}
+
+// NewCommitCachingResolver is NewCachingResolver for commands that must see tags and branches
+// as they are now: only lookups of a fixed commit, such as FileContent and CommitDate, are
+// cached, and every ref lookup reaches resolver.
+func NewCommitCachingResolver(resolver Resolver, dir string, scope string, ttl time.Duration) *CachingResolver {
+	c := NewCachingResolver(resolver, dir, scope, ttl)
+	c.commitsOnly = true
+	return c
+}
+
+// DefaultCacheDir returns the resolution cache directory under the user cache dir.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gh-pin-actions", "resolve"), nil
+}
+
+// ClearCache removes every cached resolution under dir.
+func ClearCache(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+func (c *CachingResolver) ResolveTag(repository, tag string) (string, error) {
+	return cachedRef(c, []string{"tag", repository, tag}, func() (string, error) {
+		return c.resolver.ResolveTag(repository, tag)
+	})
+}
+
+func (c *CachingResolver) ResolveBranch(repository, branch string) (string, error) {
+	return cachedRef(c, []string{"branch", repository, branch}, func() (string, error) {
+		return c.resolver.ResolveBranch(repository, branch)
+	})
+}
+
+func (c *CachingResolver) ListTags(repository string) ([]Tag, error) {
+	return cachedRef(c, []string{"tags", repository}, func() ([]Tag, error) {
+		return c.resolver.ListTags(repository)
+	})
+}
+
+func (c *CachingResolver) LatestRelease(repository string) (string, error) {
+	return cachedRef(c, []string{"latest-release", repository}, func() (string, error) {
+		return c.resolver.LatestRelease(repository)
+	})
+}
+
+func (c *CachingResolver) CommitDate(repository, sha string) (time.Time, error) {
+	return cached(c, []string{"commit-date", repository, sha}, func() (time.Time, error) {
+		return c.resolver.CommitDate(repository, sha)
+	})
+}
+
+func (c *CachingResolver) TagDate(repository, tag string) (time.Time, error) {
+	return cachedRef(c, []string{"tag-date", repository, tag}, func() (time.Time, error) {
+		return c.resolver.TagDate(repository, tag)
+	})
+}
+
+// reachability is the cached outcome of CommitReachable. Unverified commits are cached too, so
+// a repository with many refs isn't compared against the commit again on every run.
+type reachability struct {
+	Reachable  bool `json:"reachable"`
+	Unverified bool `json:"unverified"`
+}
+
+func (c *CachingResolver) CommitReachable(repository, sha string) (bool, error) {
+	result, err := cachedRef(c, []string{"reachability", repository, sha}, func() (reachability, error) {
+		reachable, err := c.resolver.CommitReachable(repository, sha)
+		if errors.Is(err, ErrUnverified) {
+			return reachability{Unverified: true}, nil
+		}
+		return reachability{Reachable: reachable}, err
+	})
+	if err != nil {
+		return false, err
+	}
+	if result.Unverified {
+		return false, fmt.Errorf("%w: commit %s in %s", ErrUnverified, sha, repository)
+	}
+	return result.Reachable, nil
+}
+
+func (c *CachingResolver) CommitVerification(repository, sha string) (Verification, error) {
+	return cached(c, []string{"verification", repository, sha}, func() (Verification, error) {
+		return c.resolver.CommitVerification(repository, sha)
+	})
+}
+
+func (c *CachingResolver) RefContains(repository, ref, sha string) (bool, error) {
+	return cachedRef(c, []string{"contains", repository, ref, sha}, func() (bool, error) {
+		return c.resolver.RefContains(repository, ref, sha)
+	})
+}
+
+func (c *CachingResolver) FileContent(repository, path, sha string) ([]byte, error) {
+	return cached(c, []string{"file", repository, path, sha}, func() ([]byte, error) {
+		return c.resolver.FileContent(repository, path, sha)
+	})
+}
+
+// Backend returns the resolver the cache sends its misses to.
+func (c *CachingResolver) Backend() Resolver {
+	return c.resolver
+}
+
+// WithBackend returns a CachingResolver sharing c's entries that sends its misses to resolver,
+// which must answer like the backend it replaces, e.g. a batching layer in front of it.
+func (c *CachingResolver) WithBackend(resolver Resolver) *CachingResolver {
+	wrapped := *c
+	wrapped.resolver = resolver
+	return &wrapped
+}
+
+// HasTags reports whether the tags of repository are cached and unexpired.
+func (c *CachingResolver) HasTags(repository string) bool {
+	if c.commitsOnly {
+		return false
+	}
+	_, ok := lookup[[]Tag](c, []string{"tags", repository})
+	return ok
+}
+
+// cachedRef caches a lookup whose answer moves with a ref, such as the commit a tag points to,
+// unless c only caches lookups of a fixed commit.
+func cachedRef[T any](c *CachingResolver, parts []string, fetch func() (T, error)) (T, error) {
+	if c.commitsOnly {
+		return fetch()
+	}
+	return cached(c, parts, fetch)
+}
+
+// cached returns the unexpired value stored under parts, or calls fetch and stores its
+// result. Cache read and write failures only cost a lookup; they never fail resolution.
+func cached[T any](c *CachingResolver, parts []string, fetch func() (T, error)) (T, error) {
+	if value, ok := lookup[T](c, parts); ok {
+		return value, nil
+	}
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+	key, path := c.entry(parts)
+	c.store(path, key, value)
+	return value, nil
+}
+
+// lookup returns the unexpired value stored under parts, if any.
+func lookup[T any](c *CachingResolver, parts []string) (T, bool) {
+	var value T
+	key, path := c.entry(parts)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return value, false
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil || entry.Key != key || c.now().Sub(entry.StoredAt) >= c.ttl ||
+		json.Unmarshal(entry.Value, &value) != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// entry returns the key of the entry stored under parts and the file holding it.
+func (c *CachingResolver) entry(parts []string) (key, path string) {
+	key = c.scope
+	for _, part := range parts {
+		key += "\x00" + part
+	}
+	sum := sha256.Sum256([]byte(key))
+	return key, filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// store writes an entry through a temporary file and a rename so readers never see a
+// partially written entry.
+func (c *CachingResolver) store(path, key string, value interface{}) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(cacheEntry{Key: key, StoredAt: c.now(), Value: raw})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,148 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// countingResolver counts the lookups that reach the wrapped resolver.
+type countingResolver struct {
+	Resolver
+	calls int
+}
+
+func (r *countingResolver) ResolveTag(repository, tag string) (string, error) {
+	r.calls++
+	return r.Resolver.ResolveTag(repository, tag)
+}
+
+func (r *countingResolver) ListTags(repository string) ([]Tag, error) {
+	r.calls++
+	return r.Resolver.ListTags(repository)
+}
+
+func TestCachingResolver(t *testing.T) {
+	const sha = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	dir := t.TempDir()
+	backend := &countingResolver{Resolver: &MemoryResolver{
+		Tags: map[string][]Tag{"actions/checkout": {{Name: "v4.2.2", SHA: sha}}},
+	}}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newCache := func() *CachingResolver {
+		c := NewCachingResolver(backend, dir, "rest", time.Hour)
+		c.now = func() time.Time { return now }
+		return c
+	}
+
+	if got, err := newCache().ResolveTag("actions/checkout", "v4.2.2"); err != nil || got != sha {
+		t.Fatalf("ResolveTag = (%q, %v), want (%q, nil)", got, err, sha)
+	}
+	// A fresh resolver over the same directory is served from disk.
+	if got, err := newCache().ResolveTag("actions/checkout", "v4.2.2"); err != nil || got != sha {
+		t.Fatalf("cached ResolveTag = (%q, %v), want (%q, nil)", got, err, sha)
+	}
+	if backend.calls != 1 {
+		t.Errorf("backend calls = %d after cache hit, want 1", backend.calls)
+	}
+	tags, err := newCache().ListTags("actions/checkout")
+	if err != nil || len(tags) != 1 || tags[0].SHA != sha {
+		t.Errorf("ListTags = (%v, %v), want the single fixture tag", tags, err)
+	}
+	if _, err := newCache().ListTags("actions/checkout"); err != nil || backend.calls != 2 {
+		t.Errorf("cached ListTags calls = %d, err = %v, want 2 calls", backend.calls, err)
+	}
+
+	// Entries of another scope are not shared.
+	other := NewCachingResolver(backend, dir, "gh", time.Hour)
+	if _, err := other.ResolveTag("actions/checkout", "v4.2.2"); err != nil || backend.calls != 3 {
+		t.Errorf("other scope calls = %d, err = %v, want 3 calls", backend.calls, err)
+	}
+
+	// Expired entries are refetched.
+	now = now.Add(2 * time.Hour)
+	if _, err := newCache().ResolveTag("actions/checkout", "v4.2.2"); err != nil || backend.calls != 4 {
+		t.Errorf("expired calls = %d, err = %v, want 4 calls", backend.calls, err)
+	}
+
+	// Failures are never cached.
+	for i := 0; i < 2; i++ {
+		if _, err := newCache().ResolveTag("actions/checkout", "v9.9.9"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ResolveTag missing error = %v, want ErrNotFound", err)
+		}
+	}
+	if backend.calls != 6 {
+		t.Errorf("backend calls = %d after failed lookups, want 6", backend.calls)
+	}
+
+	if err := ClearCache(dir); err != nil {
+		t.Fatalf("ClearCache unexpected error: %v", err)
+	}
+	if _, err := newCache().ResolveTag("actions/checkout", "v4.2.2"); err != nil || backend.calls != 7 {
+		t.Errorf("calls after clear = %d, err = %v, want 7 calls", backend.calls, err)
+	}
+}
+
+func TestCachingResolverCommitDate(t *testing.T) {
+	const sha = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	backend := &MemoryResolver{CommitDates: map[string]time.Time{sha: date}}
+	if got, err := NewCachingResolver(backend, dir, "rest", time.Hour).CommitDate("actions/checkout", sha); err != nil || !got.Equal(date) {
+		t.Fatalf("CommitDate = (%v, %v), want (%v, nil)", got, err, date)
+	}
+	// The stored date survives the JSON round-trip once the backend forgets it.
+	backend.CommitDates = nil
+	if got, err := NewCachingResolver(backend, dir, "rest", time.Hour).CommitDate("actions/checkout", sha); err != nil || !got.Equal(date) {
+		t.Errorf("cached CommitDate = (%v, %v), want (%v, nil)", got, err, date)
+	}
+}
+
+func TestCachingResolverCommitReachableUnverified(t *testing.T) {
+	const sha = "cccccccccccccccccccccccccccccccccccccccc"
+	var tags []Tag
+	for i := 0; i <= maxReachabilityCompares; i++ {
+		tags = append(tags, Tag{Name: fmt.Sprintf("v1.0.%d", i), SHA: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"})
+	}
+	dir := t.TempDir()
+	backend := &MemoryResolver{Tags: map[string][]Tag{"org/many": tags}}
+	if _, err := NewCachingResolver(backend, dir, "rest", time.Hour).CommitReachable("org/many", sha); !errors.Is(err, ErrUnverified) {
+		t.Fatalf("CommitReachable error = %v, want ErrUnverified", err)
+	}
+	// The unverified outcome is served from the cache rather than compared again.
+	backend.Tags = nil
+	if got, err := NewCachingResolver(backend, dir, "rest", time.Hour).CommitReachable("org/many", sha); !errors.Is(err, ErrUnverified) || got {
+		t.Errorf("cached CommitReachable = (%v, %v), want (false, ErrUnverified)", got, err)
+	}
+}
+
+func TestCommitCachingResolver(t *testing.T) {
+	const sha = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	dir := t.TempDir()
+	memory := &MemoryResolver{
+		Tags:  map[string][]Tag{"actions/checkout": {{Name: "v4.2.2", SHA: sha}}},
+		Files: map[string]map[string]string{"actions/checkout": {sha + ":action.yml": "name: checkout\n"}},
+	}
+	backend := &countingResolver{Resolver: memory}
+	newCache := func() *CachingResolver { return NewCommitCachingResolver(backend, dir, "rest", time.Hour) }
+
+	// Refs can move, so every tag lookup reaches the backend.
+	for i := 1; i <= 2; i++ {
+		if _, err := newCache().ResolveTag("actions/checkout", "v4.2.2"); err != nil || backend.calls != i {
+			t.Errorf("ResolveTag calls = %d, err = %v, want %d calls", backend.calls, err, i)
+		}
+	}
+	if newCache().HasTags("actions/checkout") {
+		t.Errorf("HasTags = true, want refs never served from a commit-only cache")
+	}
+
+	// File contents at a commit can't change and are served from the cache.
+	if _, err := newCache().FileContent("actions/checkout", "action.yml", sha); err != nil {
+		t.Fatalf("FileContent unexpected error: %v", err)
+	}
+	memory.Files = nil
+	if got, err := newCache().FileContent("actions/checkout", "action.yml", sha); err != nil || string(got) != "name: checkout\n" {
+		t.Errorf("cached FileContent = (%q, %v), want the stored action.yml", got, err)
+	}
+}
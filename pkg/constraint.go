@@ -0,0 +1,279 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a version constraint expression in the npm/Cargo style, such as "^4.1",
+// "~3.2.0", ">=2.5 <3" or "4 !=4.0.1". Space- or comma-separated comparators must all hold;
+// "||" separates alternatives of which any may hold. A bare partial version is a wildcard
+// range ("4" is 4.x, "4.1" is 4.1.x) and a bare full version matches exactly.
+//
+// Pre-releases only satisfy a constraint that names a pre-release version itself, so "^2"
+// never resolves to 2.1.0-rc.1 but ">=2.1.0-rc.1" can.
+type Constraint struct {
+	raw          string
+	alternatives [][]versionRange
+	prerelease   bool
+}
+
+// versionRange is the set of versions a single comparator accepts. A nil bound is unbounded;
+// exclude inverts the range, as for "!=".
+type versionRange struct {
+	min, max                   *Semver
+	minInclusive, maxInclusive bool
+	exclude                    bool
+}
+
+var (
+	comparatorRegexp = regexp.MustCompile(`^(>=|<=|!=|>|<|=|\^|~)?\s*v?([0-9xX*]+(?:\.[0-9xX*]+){0,2}(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?)$`)
+	// operatorSpaceRegexp joins an operator to the version it is separated from by spaces.
+	operatorSpaceRegexp = regexp.MustCompile(`(>=|<=|!=|>|<|=|\^|~)\s+`)
+)
+
+// ParseConstraint parses a constraint expression.
+func ParseConstraint(expr string) (Constraint, error) {
+	constraint := Constraint{raw: strings.TrimSpace(expr)}
+	if constraint.raw == "" {
+		return Constraint{}, fmt.Errorf("empty version constraint")
+	}
+	for _, alternative := range strings.Split(constraint.raw, "||") {
+		alternative = operatorSpaceRegexp.ReplaceAllString(strings.TrimSpace(alternative), "$1")
+		comparators := strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' })
+		if len(comparators) == 0 {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: empty alternative", expr)
+		}
+		var ranges []versionRange
+		for _, comparator := range comparators {
+			r, prerelease, err := parseComparator(comparator)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid version constraint %q: %w", expr, err)
+			}
+			constraint.prerelease = constraint.prerelease || prerelease
+			ranges = append(ranges, r)
+		}
+		constraint.alternatives = append(constraint.alternatives, ranges)
+	}
+	return constraint, nil
+}
+
+// parseComparator turns one comparator into the range it accepts, reporting whether it names
+// a pre-release version.
+func parseComparator(comparator string) (versionRange, bool, error) {
+	m := comparatorRegexp.FindStringSubmatch(comparator)
+	if m == nil {
+		return versionRange{}, false, fmt.Errorf("invalid comparator %q", comparator)
+	}
+	op, version := m[1], m[2]
+
+	// Count the specified numeric components; wildcards end the version early.
+	core, suffix := version, ""
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		core, suffix = version[:i], version[i:]
+	}
+	var numbers []int
+	for _, part := range strings.Split(core, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := parseNumericIdentifier(part)
+		if err != nil {
+			return versionRange{}, false, fmt.Errorf("invalid comparator %q", comparator)
+		}
+		numbers = append(numbers, n)
+	}
+	if suffix != "" && len(numbers) != 3 {
+		return versionRange{}, false, fmt.Errorf("invalid comparator %q: pre-release or build needs a full version", comparator)
+	}
+
+	full := Semver{}
+	for i, n := range numbers {
+		switch i {
+		case 0:
+			full.Major = n
+		case 1:
+			full.Minor = n
+		case 2:
+			full.Patch = n
+		}
+	}
+	if suffix != "" {
+		parsed, err := ParseSemver(core + suffix)
+		if err != nil {
+			return versionRange{}, false, fmt.Errorf("invalid comparator %q", comparator)
+		}
+		full = parsed
+	}
+	prerelease := full.IsPrerelease()
+
+	if len(numbers) == 0 {
+		// "*" or "x" matches anything, whatever the operator other than exclusion.
+		if op == "!=" || op == "<" || op == ">" {
+			return versionRange{}, false, fmt.Errorf("invalid comparator %q", comparator)
+		}
+		return versionRange{}, false, nil
+	}
+	partial := len(numbers) < 3
+	// next is the first version after the partial range, e.g. 4.2.0 for 4.1.
+	next := bumpAt(full, len(numbers)-1)
+
+	switch op {
+	case "", "=":
+		if !partial {
+			return versionRange{min: &full, max: &full, minInclusive: true, maxInclusive: true}, prerelease, nil
+		}
+		return halfOpen(full, next), false, nil
+	case "!=":
+		r := versionRange{min: &full, max: &full, minInclusive: true, maxInclusive: true}
+		if partial {
+			r = halfOpen(full, next)
+		}
+		r.exclude = true
+		// Excluding a pre-release doesn't opt the constraint into pre-releases.
+		return r, false, nil
+	case ">":
+		if partial {
+			return versionRange{min: &next, minInclusive: true}, false, nil
+		}
+		return versionRange{min: &full}, prerelease, nil
+	case ">=":
+		return versionRange{min: &full, minInclusive: true}, prerelease, nil
+	case "<":
+		if !prerelease {
+			// <3 and <3.0.0 exclude the 3.0.0 pre-releases as well.
+			full.Prerelease = "0"
+		}
+		return versionRange{max: &full}, prerelease, nil
+	case "<=":
+		if partial {
+			next.Prerelease = "0"
+			return versionRange{max: &next}, false, nil
+		}
+		return versionRange{max: &full, maxInclusive: true}, prerelease, nil
+	case "~":
+		// ~1.2.3 and ~1.2 allow patch updates, ~1 allows minor updates.
+		upper := bumpAt(full, 1)
+		if len(numbers) == 1 {
+			upper = bumpAt(full, 0)
+		}
+		return halfOpen(full, upper), prerelease, nil
+	case "^":
+		// ^ allows updates that don't change the leftmost non-zero component that was given.
+		index := 0
+		for index < len(numbers)-1 && numbers[index] == 0 {
+			index++
+		}
+		return halfOpen(full, bumpAt(full, index)), prerelease, nil
+	}
+	return versionRange{}, false, fmt.Errorf("invalid comparator %q", comparator)
+}
+
+// bumpAt increments the component at index (0 major, 1 minor, 2 patch) and zeroes the rest.
+func bumpAt(v Semver, index int) Semver {
+	switch index {
+	case 0:
+		return Semver{Major: v.Major + 1}
+	case 1:
+		return Semver{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return Semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// halfOpen returns [lower, upper), where upper also excludes its own pre-releases.
+func halfOpen(lower, upper Semver) versionRange {
+	upper.Prerelease = "0"
+	return versionRange{min: &lower, max: &upper, minInclusive: true}
+}
+
+func (r versionRange) contains(v Semver) bool {
+	in := true
+	if r.min != nil {
+		c := v.Compare(*r.min)
+		in = c > 0 || (c == 0 && r.minInclusive)
+	}
+	if in && r.max != nil {
+		c := v.Compare(*r.max)
+		in = c < 0 || (c == 0 && r.maxInclusive)
+	}
+	return in != r.exclude
+}
+
+// Check reports whether v satisfies the constraint.
+func (c Constraint) Check(v Semver) bool {
+	if v.IsPrerelease() && !c.prerelease {
+		return false
+	}
+	return c.matches(v)
+}
+
+// CheckWithPolicy reports whether v satisfies the constraint under a pre-release policy.
+// PrereleaseExclude is the same as Check. Under PrereleaseInclude and PrereleaseOnly a
+// pre-release also satisfies the constraint when its release would, so "^5" accepts 5.0.0-rc.1.
+func (c Constraint) CheckWithPolicy(v Semver, policy PrereleasePolicy) bool {
+	switch policy {
+	case PrereleaseInclude, PrereleaseOnly:
+		if !v.IsPrerelease() {
+			return policy == PrereleaseInclude && c.matches(v)
+		}
+		return c.matches(v) || c.matches(Semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch})
+	}
+	return c.Check(v)
+}
+
+// matches reports whether v falls in any alternative, ignoring pre-release eligibility.
+func (c Constraint) matches(v Semver) bool {
+	for _, alternative := range c.alternatives {
+		satisfied := true
+		for _, r := range alternative {
+			if !r.contains(v) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the expression the constraint was parsed from.
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// IsExactVersion reports whether expr names one full version, such as "4.1.1" or
+// "v2.0.0-beta.1", rather than a range.
+func IsExactVersion(expr string) bool {
+	expr = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(expr), "="), "v")
+	core, _, _ := strings.Cut(strings.SplitN(expr, "+", 2)[0], "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, part := range parts {
+		if _, err := strconv.Atoi(part); err != nil {
+			return false
+		}
+	}
+	_, err := ParseSemver(expr)
+	return err == nil
+}
+
+// HighestMatchingTag returns the semver tag with the highest precedence that satisfies
+// constraint under the pre-release policy.
+func HighestMatchingTag(tags []string, constraint Constraint, policy PrereleasePolicy) (string, error) {
+	for _, tag := range SortVersionTags(tags) {
+		tagVersion, _ := ParseSemver(tag)
+		if constraint.CheckWithPolicy(tagVersion, policy) {
+			return tag, nil
+		}
+	}
+	if policy == PrereleaseOnly {
+		return "", fmt.Errorf("no pre-release tag matching version %s", constraint)
+	}
+	return "", fmt.Errorf("no tag matching version %s", constraint)
+}
@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"^4.1", "4.1.0", true},
+		{"^4.1", "4.9.3", true},
+		{"^4.1", "4.0.9", false},
+		{"^4.1", "5.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"~3.2.0", "3.2.7", true},
+		{"~3.2.0", "3.3.0", false},
+		{"~3", "3.9.0", true},
+		{"~3", "4.0.0", false},
+		{">=2.5 <3", "2.5.0", true},
+		{">=2.5 <3", "2.9.9", true},
+		{">=2.5 <3", "3.0.0", false},
+		{">=2.5 <3", "2.4.9", false},
+		{">= 2.5, < 3", "2.6.0", true},
+		{"!=4.0.1", "4.0.1", false},
+		{"!=4.0.1", "4.0.2", true},
+		{"4 !=4.0.1", "4.0.0", true},
+		{"4 !=4.0.1", "4.0.1", false},
+		{"4 !=4.0.1", "5.0.0", false},
+		{"4", "4.7.1", true},
+		{"4.1", "4.1.5", true},
+		{"4.1", "4.2.0", false},
+		{"4.x", "4.3.0", true},
+		{"4.1.x", "4.2.0", false},
+		{"*", "9.9.9", true},
+		{"4.1.1", "4.1.1", true},
+		{"v4.1.1", "4.1.2", false},
+		{">4", "4.9.9", false},
+		{">4", "5.0.0", true},
+		{">4.1.0", "4.1.0", false},
+		{"<=4.1", "4.1.9", true},
+		{"<=4.1", "4.2.0", false},
+		{"^3 || ^5", "4.0.0", false},
+		{"^3 || ^5", "5.1.0", true},
+		// Pre-releases only match when the constraint names one.
+		{"^2", "2.1.0-rc.1", false},
+		{"<3", "3.0.0-rc.1", false},
+		{">=2.1.0-rc.1", "2.1.0-rc.2", true},
+		{">=2.1.0-rc.1", "2.1.0", true},
+		{"2.1.0-rc.1", "2.1.0-rc.1", true},
+		{"!=2.1.0-rc.1", "2.1.0-rc.2", false},
+	}
+	for _, test := range tests {
+		constraint, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing constraint %q: %v", test.constraint, err)
+		}
+		version, err := ParseSemver(test.version)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing version %s: %v", test.version, err)
+		}
+		if got := constraint.Check(version); got != test.want {
+			t.Errorf("ParseConstraint(%q).Check(%s) = %v, want %v", test.constraint, test.version, got, test.want)
+		}
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	for _, expr := range []string{"", "latest", "main", ">", "^", "4.1.1.1", "01.2", "4.1-rc.1", ">=4 ||", "!=*", "=>4"} {
+		if _, err := ParseConstraint(expr); err == nil {
+			t.Errorf("Expected error for constraint %q", expr)
+		}
+	}
+}
+
+func TestIsExactVersion(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"4.1.1", true},
+		{"v4.1.1", true},
+		{"=4.1.1", true},
+		{"2.0.0-beta.1", true},
+		{"4.1", false},
+		{"4", false},
+		{"^4.1.1", false},
+		{">=4.1.1", false},
+		{"4.1.x", false},
+	}
+	for _, test := range tests {
+		if got := IsExactVersion(test.expr); got != test.want {
+			t.Errorf("IsExactVersion(%q) = %v, want %v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestHighestMatchingTag(t *testing.T) {
+	tags := []string{"v3.6.0", "v4.0.0", "v4.0.1", "v4", "v4.1.0-rc.1", "main", "v5.0.0-beta.1"}
+	tests := []struct {
+		constraint string
+		policy     PrereleasePolicy
+		want       string
+		wantErr    bool
+	}{
+		{constraint: "4 !=4.0.1", want: "v4.0.0"},
+		{constraint: "^4", want: "v4.0.1"},
+		{constraint: ">=4.1.0-rc.1", want: "v5.0.0-beta.1"},
+		{constraint: ">=3 <4", want: "v3.6.0"},
+		{constraint: "^5", wantErr: true},
+		{constraint: "^5", policy: PrereleaseInclude, want: "v5.0.0-beta.1"},
+		{constraint: "4", policy: PrereleaseInclude, want: "v4.1.0-rc.1"},
+		{constraint: "4.0", policy: PrereleaseInclude, want: "v4.0.1"},
+		{constraint: "*", policy: PrereleaseInclude, want: "v5.0.0-beta.1"},
+		{constraint: "4", policy: PrereleaseOnly, want: "v4.1.0-rc.1"},
+		{constraint: "3", policy: PrereleaseOnly, wantErr: true},
+		{constraint: "^4", policy: PrereleaseExclude, want: "v4.0.1"},
+	}
+	for _, test := range tests {
+		constraint, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing constraint %q: %v", test.constraint, err)
+		}
+		policy := test.policy
+		if policy == "" {
+			policy = PrereleaseExclude
+		}
+		got, err := HighestMatchingTag(tags, constraint, policy)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("Unexpected error for constraint %q (%s): %v", test.constraint, policy, err)
+		case err == nil && test.wantErr:
+			t.Errorf("Expected error for constraint %q (%s), got %s", test.constraint, policy, got)
+		case got != test.want:
+			t.Errorf("HighestMatchingTag(%q, %s) = %s, want %s", test.constraint, policy, got, test.want)
+		}
+	}
+}
+
+func TestConstraintCheckWithPolicy(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		policy     PrereleasePolicy
+		want       bool
+	}{
+		{"^2", "2.1.0-rc.1", PrereleaseExclude, false},
+		{"^2", "2.1.0-rc.1", PrereleaseInclude, true},
+		{"^2", "2.1.0-rc.1", PrereleaseOnly, true},
+		{"^2", "2.1.0", PrereleaseInclude, true},
+		{"^2", "2.1.0", PrereleaseOnly, false},
+		{"<3", "3.0.0-rc.1", PrereleaseInclude, false},
+		{"!=2.1.0", "2.1.0-rc.1", PrereleaseInclude, true},
+		{"2.1.0-rc.1", "2.1.0-rc.1", PrereleaseOnly, true},
+		{"2.1.0-rc.1", "2.1.0-rc.2", PrereleaseInclude, false},
+	}
+	for _, test := range tests {
+		constraint, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing constraint %q: %v", test.constraint, err)
+		}
+		version, err := ParseSemver(test.version)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing version %s: %v", test.version, err)
+		}
+		if got := constraint.CheckWithPolicy(version, test.policy); got != test.want {
+			t.Errorf("ParseConstraint(%q).CheckWithPolicy(%s, %s) = %v, want %v", test.constraint, test.version, test.policy, got, test.want)
+		}
+	}
+}
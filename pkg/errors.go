@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+)
+
+var (
+	// ErrNotFound is returned by a Resolver when the requested repository, tag, branch or
+	// release does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited is returned when GitHub refused a request because a primary or secondary
+	// rate limit was exceeded.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUnauthorized is returned when GitHub rejected the credentials used for a request.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrUnverified is returned by CommitReachable when a repository has too many refs to
+	// prove a commit unreachable, so it may or may not be an impostor.
+	ErrUnverified = errors.New("reachability unverified")
+)
+
+// classifyHTTPError wraps an *api.HTTPError with the sentinel matching its status code so
+// callers can tell a missing tag from a rate limit with errors.Is. Other errors are returned
+// unchanged.
+func classifyHTTPError(err error) error {
+	var httpErr *api.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+	switch {
+	case httpErr.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case httpErr.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case isRateLimitResponse(httpErr.StatusCode, httpErr.Headers, httpErr.Message):
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+	return err
+}
+
+// isRateLimitResponse reports whether a response with the given status, headers and message
+// is GitHub signalling an exhausted primary quota or a secondary rate limit.
+func isRateLimitResponse(status int, header http.Header, message string) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return header.Get("X-RateLimit-Remaining") == "0" ||
+			header.Get("Retry-After") != "" ||
+			strings.Contains(strings.ToLower(message), "rate limit")
+	}
+	return false
+}
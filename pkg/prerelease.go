@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PrereleasePolicy decides whether pre-release versions may be selected when resolving a
+// version or constraint against a repository's tags.
+type PrereleasePolicy string
+
+const (
+	// PrereleaseExclude only selects a pre-release when the constraint names one. It is the default.
+	PrereleaseExclude PrereleasePolicy = "exclude"
+	// PrereleaseInclude treats pre-releases like releases, so "4" also matches 4.1.0-rc.1
+	// and 5.0.0-rc.1 is newer than the latest 4.x release.
+	PrereleaseInclude PrereleasePolicy = "include"
+	// PrereleaseOnly selects pre-releases only.
+	PrereleaseOnly PrereleasePolicy = "only"
+)
+
+// PrereleasePolicies lists the valid policies, in the order they are documented.
+var PrereleasePolicies = []PrereleasePolicy{PrereleaseExclude, PrereleaseInclude, PrereleaseOnly}
+
+// ParsePrereleasePolicy parses a policy name.
+func ParsePrereleasePolicy(name string) (PrereleasePolicy, error) {
+	for _, policy := range PrereleasePolicies {
+		if strings.EqualFold(strings.TrimSpace(name), string(policy)) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("invalid pre-release policy %q: must be one of exclude, include or only", name)
+}
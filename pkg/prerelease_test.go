@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func TestParsePrereleasePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    PrereleasePolicy
+		wantErr bool
+	}{
+		{name: "exclude", want: PrereleaseExclude},
+		{name: "include", want: PrereleaseInclude},
+		{name: "only", want: PrereleaseOnly},
+		{name: "Include", want: PrereleaseInclude},
+		{name: "", wantErr: true},
+		{name: "all", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParsePrereleasePolicy(test.name)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("Unexpected error for policy %q: %v", test.name, err)
+		case err == nil && test.wantErr:
+			t.Errorf("Expected error for policy %q", test.name)
+		case got != test.want:
+			t.Errorf("ParsePrereleasePolicy(%q) = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
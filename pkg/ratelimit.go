@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is how many times a rate-limited request is retried.
+	DefaultMaxRetries = 3
+	// defaultMaxRetryWait caps a single wait; a quota resetting later than this fails fast
+	// instead of stalling the run.
+	defaultMaxRetryWait = 2 * time.Minute
+	// baseRetryBackoff is the first wait when GitHub gives no hint of when to retry.
+	baseRetryBackoff = 2 * time.Second
+	// maxRateLimitBody bounds how much of an error body is read to spot a secondary limit.
+	maxRateLimitBody = 64 * 1024
+)
+
+// RateLimit is the quota GitHub reported in the X-RateLimit-* headers of a response.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Resource  string
+}
+
+// RetryTransport is an http.RoundTripper that retries requests GitHub rejected with a 403 or
+// 429 rate limit. It waits for Retry-After when present, otherwise until X-RateLimit-Reset
+// when the quota is exhausted, otherwise backs off exponentially.
+type RetryTransport struct {
+	Base       http.RoundTripper
+	MaxRetries int
+	MaxWait    time.Duration
+	// OnQuota, if set, is called with the quota reported by every response.
+	OnQuota func(RateLimit)
+	// OnRetry, if set, is called before waiting to retry a rate-limited request.
+	OnRetry func(attempt int, wait time.Duration)
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryTransport wraps base with the default retry policy.
+func NewRetryTransport(base http.RoundTripper) *RetryTransport {
+	return &RetryTransport{Base: base, MaxRetries: DefaultMaxRetries, MaxWait: defaultMaxRetryWait}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base().RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if quota, ok := parseRateLimit(resp.Header); ok && t.OnQuota != nil {
+			t.OnQuota(quota)
+		}
+		if attempt >= t.MaxRetries || !t.rateLimited(resp) {
+			return resp, nil
+		}
+		wait := t.retryDelay(resp.Header, attempt)
+		if t.MaxWait > 0 && wait > t.MaxWait {
+			return resp, nil
+		}
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if t.OnRetry != nil {
+			t.OnRetry(attempt+1, wait)
+		}
+		if err := t.doSleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rateLimited reports whether resp is a rate limit rejection. A 403 without rate-limit headers
+// is only a secondary limit if its body says so, so the body is peeked and restored.
+func (t *RetryTransport) rateLimited(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxRateLimitBody))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	return isRateLimitResponse(resp.StatusCode, resp.Header, string(body))
+}
+
+// retryDelay returns how long to wait before retrying a rate-limited response.
+func (t *RetryTransport) retryDelay(header http.Header, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if quota, ok := parseRateLimit(header); ok && quota.Remaining == 0 && !quota.Reset.IsZero() {
+		if wait := quota.Reset.Sub(t.clock()) + time.Second; wait > 0 {
+			return wait
+		}
+	}
+	return time.Duration(math.Pow(2, float64(attempt))) * baseRetryBackoff
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *RetryTransport) clock() time.Time {
+	if t.now == nil {
+		return time.Now()
+	}
+	return t.now()
+}
+
+func (t *RetryTransport) doSleep(ctx context.Context, d time.Duration) error {
+	if t.sleep != nil {
+		return t.sleep(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRateLimit reads the X-RateLimit-* headers, reporting false when they are absent.
+func parseRateLimit(header http.Header) (RateLimit, bool) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	quota := RateLimit{Remaining: remaining, Resource: header.Get("X-RateLimit-Resource")}
+	quota.Limit, _ = strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		quota.Reset = time.Unix(reset, 0)
+	}
+	return quota, true
+}
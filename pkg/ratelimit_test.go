@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		failures   int
+		header     map[string]string
+		status     int
+		body       string
+		maxRetries int
+		wantStatus int
+		wantWaits  []time.Duration
+	}{
+		{
+			name:       "retry-after honoured",
+			failures:   1,
+			status:     http.StatusForbidden,
+			header:     map[string]string{"Retry-After": "30"},
+			body:       `{"message":"You have exceeded a secondary rate limit"}`,
+			maxRetries: 3,
+			wantStatus: http.StatusOK,
+			wantWaits:  []time.Duration{30 * time.Second},
+		},
+		{
+			name:     "waits for quota reset",
+			failures: 1,
+			status:   http.StatusForbidden,
+			header: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(now.Add(10*time.Second).Unix(), 10),
+			},
+			body:       `{"message":"API rate limit exceeded"}`,
+			maxRetries: 3,
+			wantStatus: http.StatusOK,
+			wantWaits:  []time.Duration{11 * time.Second},
+		},
+		{
+			name:       "secondary limit backs off exponentially",
+			failures:   2,
+			status:     http.StatusForbidden,
+			body:       `{"message":"You have exceeded a secondary rate limit"}`,
+			maxRetries: 3,
+			wantStatus: http.StatusOK,
+			wantWaits:  []time.Duration{2 * time.Second, 4 * time.Second},
+		},
+		{
+			name:       "too many requests gives up after max retries",
+			failures:   5,
+			status:     http.StatusTooManyRequests,
+			maxRetries: 2,
+			wantStatus: http.StatusTooManyRequests,
+			wantWaits:  []time.Duration{2 * time.Second, 4 * time.Second},
+		},
+		{
+			name:       "plain forbidden is not retried",
+			failures:   1,
+			status:     http.StatusForbidden,
+			body:       `{"message":"Resource not accessible by integration"}`,
+			maxRetries: 3,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:     "reset beyond max wait fails fast",
+			failures: 1,
+			status:   http.StatusForbidden,
+			header: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(now.Add(time.Hour).Unix(), 10),
+			},
+			maxRetries: 3,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				requests++
+				if requests <= tt.failures {
+					for k, v := range tt.header {
+						w.Header().Set(k, v)
+					}
+					writeJSON(w, tt.status, tt.body)
+					return
+				}
+				writeJSON(w, http.StatusOK, `{}`)
+			}))
+			defer server.Close()
+
+			var waits []time.Duration
+			transport := NewRetryTransport(nil)
+			transport.MaxRetries = tt.maxRetries
+			transport.now = func() time.Time { return now }
+			transport.sleep = func(_ context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if fmt.Sprint(waits) != fmt.Sprint(tt.wantWaits) {
+				t.Errorf("waits = %v, want %v", waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+func TestRESTResolverReportsQuota(t *testing.T) {
+	var quotas []RateLimit
+	transport := NewRetryTransport(nil)
+	transport.OnQuota = func(quota RateLimit) { quotas = append(quotas, quota) }
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Resource", "core")
+		writeJSON(w, http.StatusOK, `{"tag_name":"v1.0.0"}`)
+	}))
+	defer server.Close()
+	target := mustParseURL(t, server.URL)
+	transport.Base = rewriteTransport{target: target}
+	r, err := NewRESTResolver(apiOptions(transport))
+	if err != nil {
+		t.Fatalf("Unexpected error creating resolver: %v", err)
+	}
+	if _, err := r.LatestRelease("owner/repo"); err != nil {
+		t.Fatalf("LatestRelease unexpected error: %v", err)
+	}
+	if len(quotas) != 1 || quotas[0].Remaining != 4321 || quotas[0].Limit != 5000 || quotas[0].Resource != "core" {
+		t.Errorf("reported quotas = %+v, want one core quota of 4321/5000", quotas)
+	}
+}
+
+func TestClassifyGhError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    error
+	}{
+		{"gh api: exit status 1: gh: Not Found (HTTP 404)", ErrNotFound},
+		{"gh api: exit status 1: gh: Bad credentials (HTTP 401)", ErrUnauthorized},
+		{"gh api: exit status 1: gh: API rate limit exceeded for user (HTTP 403)", ErrRateLimited},
+		{"gh api: exit status 1: gh: Too Many Requests (HTTP 429)", ErrRateLimited},
+	}
+	for _, tt := range tests {
+		if err := classifyGhError(errors.New(tt.message)); !errors.Is(err, tt.want) {
+			t.Errorf("classifyGhError(%q) = %v, want %v", tt.message, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxReachabilityCompares bounds how many branches and tags are compared against a commit
+// before giving up on proving it reachable.
+const maxReachabilityCompares = 50
+
+// commitReachable reports whether sha is reachable from one of branches or tags. Refs pointing
+// at sha answer straight away; otherwise compare is asked for the GitHub compare status of sha
+// against each ref in turn, where "behind" or "identical" means the ref contains sha. The
+// default branch is compared first, as it contains most pinned commits.
+//
+// GitHub serves commits from any fork of a repository through the parent's path, so a commit
+// that exists but is reachable from none of the repository's own refs is an impostor. Past
+// maxReachabilityCompares refs the commit is neither proven reachable nor unreachable, and
+// ErrUnverified is returned.
+func commitReachable(sha, defaultBranch string, branches, tags []Tag, compare func(ref string) (string, error)) (bool, error) {
+	refs := append(append([]Tag{}, branches...), tags...)
+	for _, ref := range refs {
+		if ref.SHA == sha {
+			return true, nil
+		}
+	}
+	names := make([]string, 0, len(refs)+1)
+	if defaultBranch != "" {
+		names = append(names, defaultBranch)
+	}
+	for _, ref := range refs {
+		if ref.Name != defaultBranch {
+			names = append(names, ref.Name)
+		}
+	}
+	for i, name := range names {
+		if i == maxReachabilityCompares {
+			return false, fmt.Errorf("%w: commit %s is not contained in the first %d of %d refs", ErrUnverified, sha, maxReachabilityCompares, len(names))
+		}
+		status, err := compare(name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if status == "behind" || status == "identical" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCommitReachable(t *testing.T) {
+	const (
+		shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		shaC = "cccccccccccccccccccccccccccccccccccccccc"
+	)
+	branches := []Tag{{Name: "main", SHA: shaA}}
+	tags := []Tag{{Name: "v1.0.0", SHA: shaB}}
+	tests := []struct {
+		name     string
+		sha      string
+		statuses map[string]string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "branch head", sha: shaA, want: true},
+		{name: "tagged commit", sha: shaB, want: true},
+		{name: "ancestor of a branch", sha: shaC, statuses: map[string]string{"main": "behind", "v1.0.0": "diverged"}, want: true},
+		{name: "ancestor of a tag", sha: shaC, statuses: map[string]string{"main": "diverged", "v1.0.0": "behind"}, want: true},
+		{name: "impostor", sha: shaC, statuses: map[string]string{"main": "diverged", "v1.0.0": "ahead"}, want: false},
+		{name: "compare failure", sha: shaC, statuses: map[string]string{}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := commitReachable(test.sha, "main", branches, tags, func(ref string) (string, error) {
+				status, ok := test.statuses[ref]
+				if !ok {
+					return "", fmt.Errorf("compare %s failed", ref)
+				}
+				return status, nil
+			})
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("commitReachable(%s) expected error, got %v", test.name, got)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("commitReachable(%s) = (%v, %v), want (%v, nil)", test.name, got, err, test.want)
+			}
+		})
+	}
+
+	// The default branch is compared before the tags, however many there are.
+	many := make([]Tag, maxReachabilityCompares+1)
+	for i := range many {
+		many[i] = Tag{Name: fmt.Sprintf("v1.0.%d", i), SHA: shaA}
+	}
+	var compared []string
+	got, err := commitReachable(shaC, "main", []Tag{{Name: "feature", SHA: shaB}, {Name: "main", SHA: shaB}}, many, func(ref string) (string, error) {
+		compared = append(compared, ref)
+		if ref == "main" {
+			return "behind", nil
+		}
+		return "diverged", nil
+	})
+	if err != nil || !got || fmt.Sprint(compared) != "[main]" {
+		t.Errorf("commitReachable on the default branch = (%v, %v) after comparing %v, want (true, nil) after [main]", got, err, compared)
+	}
+
+	// Refs beyond the compare limit can't prove the commit unreachable, so it is unverified.
+	if _, err := commitReachable(shaC, "main", nil, many, func(string) (string, error) { return "diverged", nil }); !errors.Is(err, ErrUnverified) {
+		t.Errorf("commitReachable past the compare limit error = %v, want ErrUnverified", err)
+	}
+}
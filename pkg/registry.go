@@ -0,0 +1,243 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// dockerHubRegistry is the registry host serving images named without one, such as node:20.
+	dockerHubRegistry = "registry-1.docker.io"
+	// defaultImageTag is the tag of an image named without a tag or digest.
+	defaultImageTag = "latest"
+)
+
+// manifestMediaTypes are the manifest formats accepted from a registry. Multi-platform
+// indexes come first so a tag resolves to the digest `docker pull` would use.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var (
+	digestRegexp       = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+	authenticateRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	imageNameRegexp    = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._:/-]*$`)
+	imageTagRegexp     = regexp.MustCompile(`^[\w][\w.-]*$`)
+)
+
+// ImageRef is a container image reference, as used by `docker://` steps and by job
+// container: and services: images.
+type ImageRef struct {
+	// Name is the image as written, without its tag or digest, e.g. ghcr.io/org/tool or node.
+	Name string
+	// Registry is the host serving the image and Repository its path there, with Docker Hub
+	// names expanded (node is library/node on registry-1.docker.io).
+	Registry   string
+	Repository string
+	// Tag is the tag named, "latest" when neither a tag nor a digest is given.
+	Tag string
+	// Digest is the sha256 digest the reference is pinned to, if any.
+	Digest string
+}
+
+// ParseImageRef parses an image reference such as ghcr.io/org/tool:1.4, node:20 or
+// alpine@sha256:<digest>.
+func ParseImageRef(image string) (ImageRef, error) {
+	var ref ImageRef
+	name := strings.TrimSpace(image)
+	if before, digest, ok := strings.Cut(name, "@"); ok {
+		if !digestRegexp.MatchString(digest) {
+			return ImageRef{}, fmt.Errorf("invalid image digest: %s", image)
+		}
+		name, ref.Digest = before, digest
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+		if !imageTagRegexp.MatchString(ref.Tag) {
+			return ImageRef{}, fmt.Errorf("invalid image tag: %s", image)
+		}
+	}
+	if !imageNameRegexp.MatchString(name) {
+		return ImageRef{}, fmt.Errorf("invalid image reference: %s", image)
+	}
+	ref.Name = name
+
+	registry, repository, ok := strings.Cut(name, "/")
+	if !ok || !(strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		registry, repository = "docker.io", name
+	}
+	if registry == "docker.io" || registry == "index.docker.io" {
+		registry = dockerHubRegistry
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+	ref.Registry, ref.Repository = registry, repository
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultImageTag
+	}
+	return ref, nil
+}
+
+// ImageResolver resolves container image tags to the digests they point to. RegistryClient
+// talks to registries; MemoryRegistry serves fixtures in tests.
+type ImageResolver interface {
+	// Digest returns the digest of the manifest ref's tag points to, such as sha256:<hex>.
+	Digest(ref ImageRef) (string, error)
+}
+
+// RegistryClient resolves image tags to manifest digests through the OCI distribution API.
+// Registries that require a token are sent an anonymous one, so only public images resolve.
+type RegistryClient struct {
+	client *http.Client
+}
+
+// NewRegistryClient returns a RegistryClient sending requests through transport, or through
+// http.DefaultTransport when transport is nil.
+func NewRegistryClient(transport http.RoundTripper) *RegistryClient {
+	return &RegistryClient{client: &http.Client{Transport: transport}}
+}
+
+// Digest returns the digest of the manifest ref's tag points to, such as sha256:<hex>. A ref
+// that is already pinned returns its own digest.
+func (c *RegistryClient) Digest(ref ImageRef) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", registryScheme(ref.Registry), ref.Registry, ref.Repository, url.PathEscape(ref.Tag))
+	// HEAD doesn't count against pull limits; registries that leave out the digest header
+	// get a GET, and the digest is computed from the manifest itself.
+	resp, err := c.fetchManifest(http.MethodHead, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); digestRegexp.MatchString(digest) {
+		return digest, nil
+	}
+	resp, err = c.fetchManifest(http.MethodGet, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	manifest, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(manifest)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// fetchManifest requests a manifest, retrying once with an anonymous bearer token when the
+// registry asks for one.
+func (c *RegistryClient) fetchManifest(method, manifestURL string, ref ImageRef) (*http.Response, error) {
+	resp, err := c.manifestRequest(method, manifestURL, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		token, err := c.anonymousToken(resp.Header.Get("WWW-Authenticate"), ref)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.manifestRequest(method, manifestURL, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	resp.Body.Close()
+	image := ref.Name + ":" + ref.Tag
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: image %s", ErrNotFound, image)
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("%w: registry %s", ErrRateLimited, ref.Registry)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("registry %s denied access to %s; only public images can be pinned", ref.Registry, image)
+	}
+	return nil, fmt.Errorf("registry %s returned %s for %s", ref.Registry, resp.Status, image)
+}
+
+func (c *RegistryClient) manifestRequest(method, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.client.Do(req)
+}
+
+// anonymousToken requests a pull token from the realm of a Bearer challenge, as in
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`.
+func (c *RegistryClient) anonymousToken(challenge string, ref ImageRef) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry %s requires unsupported authentication %q", ref.Registry, scheme)
+	}
+	values := map[string]string{}
+	for _, m := range authenticateRegexp.FindAllStringSubmatch(params, -1) {
+		values[m[1]] = m[2]
+	}
+	if values["realm"] == "" {
+		return "", fmt.Errorf("registry %s sent a token challenge without a realm", ref.Registry)
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull"
+	}
+	query := url.Values{"scope": {scope}}
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	resp, err := c.client.Get(values["realm"] + "?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry %s refused an anonymous token: %s", ref.Registry, resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// registryScheme returns "http" for registries on the loopback interface, as Docker allows,
+// and "https" otherwise.
+func registryScheme(registry string) string {
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return "http"
+	}
+	return "https"
+}
@@ -0,0 +1,21 @@
+package pkg
+
+import "fmt"
+
+// MemoryRegistry is an in-memory ImageResolver populated from fixtures, used to exercise
+// image pinning without talking to a registry. Digests is keyed by image name and tag as
+// written in a workflow, such as ghcr.io/org/tool:1.4.
+type MemoryRegistry struct {
+	Digests map[string]string
+}
+
+func (r *MemoryRegistry) Digest(ref ImageRef) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	digest, ok := r.Digests[ref.Name+":"+ref.Tag]
+	if !ok {
+		return "", fmt.Errorf("image %s:%s: %w", ref.Name, ref.Tag, ErrNotFound)
+	}
+	return digest, nil
+}
@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		image   string
+		want    ImageRef
+		wantErr bool
+	}{
+		{image: "ghcr.io/org/tool:1.4", want: ImageRef{Name: "ghcr.io/org/tool", Registry: "ghcr.io", Repository: "org/tool", Tag: "1.4"}},
+		{image: "node:20", want: ImageRef{Name: "node", Registry: "registry-1.docker.io", Repository: "library/node", Tag: "20"}},
+		{image: "bitnami/redis", want: ImageRef{Name: "bitnami/redis", Registry: "registry-1.docker.io", Repository: "bitnami/redis", Tag: "latest"}},
+		{image: "docker.io/library/alpine:3.19", want: ImageRef{Name: "docker.io/library/alpine", Registry: "registry-1.docker.io", Repository: "library/alpine", Tag: "3.19"}},
+		{image: "localhost:5000/tool:v1", want: ImageRef{Name: "localhost:5000/tool", Registry: "localhost:5000", Repository: "tool", Tag: "v1"}},
+		{image: "alpine@" + testDigest, want: ImageRef{Name: "alpine", Registry: "registry-1.docker.io", Repository: "library/alpine", Digest: testDigest}},
+		{image: "alpine:3.19@" + testDigest, want: ImageRef{Name: "alpine", Registry: "registry-1.docker.io", Repository: "library/alpine", Tag: "3.19", Digest: testDigest}},
+		{image: "alpine@sha256:abc", wantErr: true},
+		{image: "${{ matrix.image }}", wantErr: true},
+		{image: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseImageRef(test.image)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("ParseImageRef(%q) unexpected error: %v", test.image, err)
+		case err == nil && test.wantErr:
+			t.Errorf("ParseImageRef(%q) = %+v, want error", test.image, got)
+		case got != test.want:
+			t.Errorf("ParseImageRef(%q) = %+v, want %+v", test.image, got, test.want)
+		}
+	}
+}
+
+// newTestRegistry starts a registry serving manifests keyed by repository:tag. Like Docker Hub
+// and ghcr.io it hands out anonymous tokens; without digestHeader it leaves out
+// Docker-Content-Digest, so clients must hash the manifest.
+func newTestRegistry(t *testing.T, manifests map[string]string, digestHeader bool) *httptest.Server {
+	t.Helper()
+	const token = "anonymous-token"
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") == "" {
+				http.Error(w, "missing scope", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"token": %q}`, token)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		repository, tag, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+		manifest, found := manifests[repository+":"+tag]
+		if !ok || !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if digestHeader {
+			sum := sha256.Sum256([]byte(manifest))
+			w.Header().Set("Docker-Content-Digest", "sha256:"+hex.EncodeToString(sum[:]))
+		}
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, manifest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRegistryClientDigest(t *testing.T) {
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+	sum := sha256.Sum256([]byte(manifest))
+	want := "sha256:" + hex.EncodeToString(sum[:])
+
+	for _, digestHeader := range []bool{true, false} {
+		server := newTestRegistry(t, map[string]string{"org/tool:1.4": manifest}, digestHeader)
+		host := strings.TrimPrefix(server.URL, "http://")
+		client := NewRegistryClient(nil)
+
+		ref, err := ParseImageRef(host + "/org/tool:1.4")
+		if err != nil {
+			t.Fatalf("ParseImageRef unexpected error: %v", err)
+		}
+		if got, err := client.Digest(ref); err != nil || got != want {
+			t.Errorf("Digest (digest header %v) = %q, %v, want %q", digestHeader, got, err, want)
+		}
+
+		missing, _ := ParseImageRef(host + "/org/tool:9.9")
+		if _, err := client.Digest(missing); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Digest of a missing tag error = %v, want ErrNotFound", err)
+		}
+	}
+
+	pinned, _ := ParseImageRef("ghcr.io/org/tool@" + testDigest)
+	if got, err := NewRegistryClient(nil).Digest(pinned); err != nil || got != testDigest {
+		t.Errorf("Digest of a pinned ref = %q, %v, want %q", got, err, testDigest)
+	}
+}
+
+func TestRegistryScheme(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io":         "https",
+		"localhost:5000":  "http",
+		"127.0.0.1:5000":  "http",
+		"[::1]:5000":      "http",
+		"registry.local":  "https",
+		"10.0.0.1:5000":   "https",
+		dockerHubRegistry: "https",
+	}
+	for registry, want := range tests {
+		if got := registryScheme(registry); got != want {
+			t.Errorf("registryScheme(%q) = %q, want %q", registry, got, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import "time"
+
+// Tag is a git tag of an action repository and the commit SHA it points to.
+type Tag struct {
+	Name string
+	SHA  string
+}
+
+// Verification is the signature verification status of a commit. Reason follows GitHub's
+// vocabulary, such as "valid", "unsigned" or "unknown_key".
+type Verification struct {
+	Verified bool
+	Reason   string
+}
+
+// Resolver looks up the refs of an action repository. Each implementation is backed by a
+// different source (the REST API, the gh CLI, an in-memory fixture for tests) so resolution can be swapped
+// without touching the pinning logic. Repositories are given in the owner/repo format.
+type Resolver interface {
+	// ResolveTag returns the commit SHA the named tag points to, peeling annotated tags so
+	// the result is never a tag object.
+	ResolveTag(repository, tag string) (string, error)
+	// ResolveBranch returns the commit SHA at the head of the named branch.
+	ResolveBranch(repository, branch string) (string, error)
+	// ListTags returns the tags of the repository.
+	ListTags(repository string) ([]Tag, error)
+	// LatestRelease returns the tag name of the repository's latest release.
+	LatestRelease(repository string) (string, error)
+	// CommitDate returns the committer date of the commit with the given SHA.
+	CommitDate(repository, sha string) (time.Time, error)
+	// TagDate returns when the named tag was published: the publication date of its release,
+	// or else the tagger date of an annotated tag. Lightweight tags without a release have
+	// neither and return ErrNotFound.
+	TagDate(repository, tag string) (time.Time, error)
+	// CommitReachable reports whether the commit is reachable from a branch or tag of the
+	// repository itself, rather than only existing in a fork that shares its object storage.
+	CommitReachable(repository, sha string) (bool, error)
+	// RefContains reports whether the commit is the named branch or tag or one of its ancestors.
+	RefContains(repository, ref, sha string) (bool, error)
+	// CommitVerification returns whether the commit carries a verified GPG, SSH or S/MIME
+	// signature.
+	CommitVerification(repository, sha string) (Verification, error)
+	// FileContent returns the content of the file at path, relative to the repository root,
+	// in the commit with the given SHA.
+	FileContent(repository, path, sha string) ([]byte, error)
+}
+
+// TagNames returns the names of tags, in order.
+func TagNames(tags []Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
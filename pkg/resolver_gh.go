@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/v2"
+)
+
+// GhResolver resolves refs by shelling out to the gh CLI, reusing its authentication.
+// Commands gh reports as rate limited are retried with exponential backoff. An empty Hostname
+// leaves the host to gh (GH_HOST or its configured default).
+type GhResolver struct {
+	Hostname   string
+	MaxRetries int
+
+	sleep func(time.Duration)
+}
+
+// NewGhResolver returns a Resolver backed by the gh CLI.
+func NewGhResolver() *GhResolver {
+	return &GhResolver{MaxRetries: DefaultMaxRetries}
+}
+
+// ResolveTag looks refs/tags/<tag> up directly and peels annotated tags, so the returned SHA
+// is always a commit and never a tag object.
+func (r *GhResolver) ResolveTag(repository, tag string) (string, error) {
+	jq := fmt.Sprintf(`.[] | select(.ref == %q) | "\(.object.type) \(.object.sha)"`, "refs/tags/"+tag)
+	out, err := r.exec("api", fmt.Sprintf("repos/%s/git/matching-refs/tags/%s", repository, escapeRefPath(tag)), "--jq", jq)
+	if err != nil {
+		return "", err
+	}
+	for depth := 0; depth < maxTagPeelDepth; depth++ {
+		fields := strings.Fields(out)
+		if len(fields) != 2 {
+			return "", fmt.Errorf("tag %s in %s: %w", tag, repository, ErrNotFound)
+		}
+		objectType, sha := fields[0], fields[1]
+		switch objectType {
+		case "commit":
+			return sha, nil
+		case "tag":
+			out, err = r.exec("api", fmt.Sprintf("repos/%s/git/tags/%s", repository, sha), "--jq", `"\(.object.type) \(.object.sha)"`)
+			if err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("tag %s in %s points to a %s, not a commit", tag, repository, objectType)
+		}
+	}
+	return "", fmt.Errorf("tag %s in %s is nested more than %d tags deep", tag, repository, maxTagPeelDepth)
+}
+
+func (r *GhResolver) ResolveBranch(repository, branch string) (string, error) {
+	out, err := r.exec("api", fmt.Sprintf("repos/%s/branches/%s", repository, escapeRefPath(branch)), "--jq", ".commit.sha")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (r *GhResolver) ListTags(repository string) ([]Tag, error) {
+	out, err := r.exec("api", "--paginate", fmt.Sprintf("repos/%s/tags?per_page=100", repository), "--jq", `.[] | "\(.name) \(.commit.sha)"`)
+	if err != nil {
+		return nil, err
+	}
+	var tags []Tag
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		tags = append(tags, Tag{Name: fields[0], SHA: fields[1]})
+	}
+	return tags, nil
+}
+
+func (r *GhResolver) LatestRelease(repository string) (string, error) {
+	repoArg := repository
+	if r.Hostname != "" {
+		repoArg = r.Hostname + "/" + repository
+	}
+	out, err := r.exec("release", "view", "-R", repoArg, "--json", "tagName", "--jq", ".tagName")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (r *GhResolver) CommitDate(repository, sha string) (time.Time, error) {
+	out, err := r.exec("api", fmt.Sprintf("repos/%s/commits/%s", repository, sha), "--jq", ".commit.committer.date")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(out))
+}
+
+// TagDate reads the publication date of the tag's release, falling back to the tagger date
+// when the tag has no published release and is annotated.
+func (r *GhResolver) TagDate(repository, tag string) (time.Time, error) {
+	out, err := r.exec("api", fmt.Sprintf("repos/%s/releases/tags/%s", repository, escapeRefPath(tag)), "--jq", ".published_at // empty")
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return time.Time{}, err
+	}
+	if err == nil && strings.TrimSpace(out) != "" {
+		return time.Parse(time.RFC3339, strings.TrimSpace(out))
+	}
+	jq := fmt.Sprintf(`.[] | select(.ref == %q and .object.type == "tag") | .object.sha`, "refs/tags/"+tag)
+	out, err = r.exec("api", fmt.Sprintf("repos/%s/git/matching-refs/tags/%s", repository, escapeRefPath(tag)), "--jq", jq)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if sha := strings.TrimSpace(out); sha != "" {
+		out, err = r.exec("api", fmt.Sprintf("repos/%s/git/tags/%s", repository, sha), "--jq", ".tagger.date // empty")
+		if err != nil {
+			return time.Time{}, err
+		}
+		if date := strings.TrimSpace(out); date != "" {
+			return time.Parse(time.RFC3339, date)
+		}
+	}
+	return time.Time{}, fmt.Errorf("release or annotated tag %s in %s: %w", tag, repository, ErrNotFound)
+}
+
+func (r *GhResolver) CommitVerification(repository, sha string) (Verification, error) {
+	out, err := r.exec("api", fmt.Sprintf("repos/%s/commits/%s", repository, sha), "--jq", `"\(.commit.verification.verified) \(.commit.verification.reason)"`)
+	if err != nil {
+		return Verification{}, err
+	}
+	verified, reason, _ := strings.Cut(strings.TrimSpace(out), " ")
+	return Verification{Verified: verified == "true", Reason: reason}, nil
+}
+
+func (r *GhResolver) FileContent(repository, path, sha string) ([]byte, error) {
+	out, err := r.exec("api", fmt.Sprintf("repos/%s/contents/%s?ref=%s", repository, escapeRefPath(path), sha), "--jq", "select(.type == \"file\") | .content")
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(out) == "" {
+		return nil, fmt.Errorf("%s at %s in %s is not a file: %w", path, sha, repository, ErrNotFound)
+	}
+	return decodeContent(out)
+}
+
+// CommitReachable looks for a branch or tag containing sha, first among the refs pointing at it
+// and then through the compare API. Commits GitHub doesn't know at all are unreachable.
+func (r *GhResolver) CommitReachable(repository, sha string) (bool, error) {
+	if _, err := r.CommitDate(repository, sha); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	defaultBranch, err := r.exec("api", fmt.Sprintf("repos/%s", repository), "--jq", ".default_branch")
+	if err != nil {
+		return false, err
+	}
+	out, err := r.exec("api", "--paginate", fmt.Sprintf("repos/%s/branches?per_page=100", repository), "--jq", `.[] | "\(.name) \(.commit.sha)"`)
+	if err != nil {
+		return false, err
+	}
+	var branches []Tag
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			branches = append(branches, Tag{Name: fields[0], SHA: fields[1]})
+		}
+	}
+	tags, err := r.ListTags(repository)
+	if err != nil {
+		return false, err
+	}
+	return commitReachable(sha, strings.TrimSpace(defaultBranch), branches, tags, func(ref string) (string, error) {
+		return r.compareStatus(repository, ref, sha)
+	})
+}
+
+func (r *GhResolver) RefContains(repository, ref, sha string) (bool, error) {
+	status, err := r.compareStatus(repository, ref, sha)
+	if err != nil {
+		return false, err
+	}
+	return status == "behind" || status == "identical", nil
+}
+
+// compareStatus returns the status of comparing head against base: "identical", "behind"
+// when base contains head, "ahead" or "diverged".
+func (r *GhResolver) compareStatus(repository, base, head string) (string, error) {
+	out, err := r.exec("api", fmt.Sprintf("repos/%s/compare/%s...%s?per_page=1", repository, escapeRefPath(base), head), "--jq", ".status")
+	return strings.TrimSpace(out), err
+}
+
+// exec runs gh with args, folding its stderr into the returned error and retrying when gh
+// reports a rate limit.
+func (r *GhResolver) exec(args ...string) (string, error) {
+	command := strings.Join(args[:2], " ")
+	if args[0] == "api" && r.Hostname != "" {
+		args = append([]string{"api", "--hostname", r.Hostname}, args[1:]...)
+	}
+	for attempt := 0; ; attempt++ {
+		stdOut, stdErr, err := gh.Exec(args...)
+		if err == nil {
+			return stdOut.String(), nil
+		}
+		err = classifyGhError(fmt.Errorf("gh %s: %w: %s", command, err, strings.TrimSpace(stdErr.String())))
+		if !errors.Is(err, ErrRateLimited) || attempt >= r.MaxRetries {
+			return "", err
+		}
+		wait := time.Duration(math.Pow(2, float64(attempt))) * baseRetryBackoff
+		if r.sleep != nil {
+			r.sleep(wait)
+		} else {
+			time.Sleep(wait)
+		}
+	}
+}
+
+// classifyGhError wraps an error from gh with the sentinel matching the HTTP status gh printed.
+func classifyGhError(err error) error {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "HTTP 404"):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case strings.Contains(message, "HTTP 401"):
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case strings.Contains(message, "HTTP 429") || strings.Contains(strings.ToLower(message), "rate limit"):
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	}
+	return err
+}
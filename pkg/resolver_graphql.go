@@ -0,0 +1,268 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+)
+
+const (
+	// graphQLReposPerQuery bounds how many repositories are folded into one query so the
+	// query stays well within GitHub's node limits.
+	graphQLReposPerQuery = 25
+	// graphQLTagsPerRepo is the largest page of tags a refs connection serves.
+	graphQLTagsPerRepo = 100
+)
+
+// RepoRefs names a repository and the branches of it to prefetch.
+type RepoRefs struct {
+	Repository string
+	Branches   []string
+}
+
+// GraphQLResolver answers lookups from refs prefetched with batched GraphQL queries, which
+// resolve the tags, latest release and requested branches of many repositories in a single
+// round-trip. Anything that was not prefetched is delegated to the fallback Resolver.
+type GraphQLResolver struct {
+	client   *api.GraphQLClient
+	fallback Resolver
+
+	mu    sync.RWMutex
+	repos map[string]*repoSnapshot
+}
+
+// repoSnapshot holds the prefetched refs of one repository.
+type repoSnapshot struct {
+	tags          []Tag
+	tagsComplete  bool
+	latestRelease string
+	branches      map[string]string
+}
+
+// NewGraphQLResolver returns a GraphQLResolver querying the host in opts and delegating
+// lookups it cannot answer to fallback.
+func NewGraphQLResolver(opts api.ClientOptions, fallback Resolver) (*GraphQLResolver, error) {
+	client, err := api.NewGraphQLClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &GraphQLResolver{client: client, fallback: fallback, repos: map[string]*repoSnapshot{}}, nil
+}
+
+// Prefetch resolves the tags, latest release and branches of every repository in refs.
+// Repositories GitHub reports errors for are left to the fallback; the returned error only
+// signals that a whole batch failed.
+func (r *GraphQLResolver) Prefetch(refs []RepoRefs) error {
+	var errs []error
+	for start := 0; start < len(refs); start += graphQLReposPerQuery {
+		end := start + graphQLReposPerQuery
+		if end > len(refs) {
+			end = len(refs)
+		}
+		if err := r.prefetchBatch(refs[start:end]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// graphQLTarget is a git object a ref points at, nesting through annotated tags.
+type graphQLTarget struct {
+	Typename string         `json:"__typename"`
+	OID      string         `json:"oid"`
+	Target   *graphQLTarget `json:"target"`
+}
+
+// commit peels annotated tags down to the commit they point at.
+func (t *graphQLTarget) commit() (string, bool) {
+	for depth := 0; t != nil && depth < maxTagPeelDepth; depth++ {
+		switch t.Typename {
+		case "Commit":
+			return t.OID, true
+		case "Tag":
+			t = t.Target
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+type graphQLRepository struct {
+	Tags struct {
+		PageInfo struct {
+			HasNextPage bool `json:"hasNextPage"`
+		} `json:"pageInfo"`
+		Nodes []struct {
+			Name   string        `json:"name"`
+			Target graphQLTarget `json:"target"`
+		} `json:"nodes"`
+	} `json:"tags"`
+	LatestRelease *struct {
+		TagName string `json:"tagName"`
+	} `json:"latestRelease"`
+}
+
+type graphQLBranch struct {
+	Target graphQLTarget `json:"target"`
+}
+
+// graphQLTargetFields selects a target and up to three levels of annotated tags behind it.
+const graphQLTargetFields = `target { __typename oid ... on Tag { target { __typename oid ... on Tag { target { __typename oid } } } } }`
+
+func (r *GraphQLResolver) prefetchBatch(refs []RepoRefs) error {
+	query, variables := buildRefsQuery(refs)
+	var data map[string]json.RawMessage
+	err := r.client.Do(query, variables, &data)
+	var gqlErr *api.GraphQLError
+	if err != nil && !errors.As(err, &gqlErr) {
+		return classifyHTTPError(err)
+	}
+	// Partial errors (e.g. a repository that doesn't exist) still return data for the rest.
+	for i, ref := range refs {
+		raw, ok := data[fmt.Sprintf("r%d", i)]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		snapshot, err := parseRepoSnapshot(raw, ref.Branches)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.repos[ref.Repository] = snapshot
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// buildRefsQuery builds one query aliasing each repository as r<i> and each of its branches
+// as b<j>, passing names as variables so they never need escaping.
+func buildRefsQuery(refs []RepoRefs) (string, map[string]interface{}) {
+	var params, body strings.Builder
+	variables := map[string]interface{}{}
+	for i, ref := range refs {
+		owner, name, _ := strings.Cut(ref.Repository, "/")
+		variables[fmt.Sprintf("o%d", i)] = owner
+		variables[fmt.Sprintf("n%d", i)] = name
+		fmt.Fprintf(&params, "$o%d: String!, $n%d: String!, ", i, i)
+		fmt.Fprintf(&body, "r%d: repository(owner: $o%d, name: $n%d) { ", i, i, i)
+		fmt.Fprintf(&body, `tags: refs(refPrefix: "refs/tags/", first: %d) { pageInfo { hasNextPage } nodes { name %s } } `,
+			graphQLTagsPerRepo, graphQLTargetFields)
+		body.WriteString("latestRelease { tagName } ")
+		for j, branch := range ref.Branches {
+			variables[fmt.Sprintf("b%d_%d", i, j)] = "refs/heads/" + branch
+			fmt.Fprintf(&params, "$b%d_%d: String!, ", i, j)
+			fmt.Fprintf(&body, "b%d: ref(qualifiedName: $b%d_%d) { %s } ", j, i, j, graphQLTargetFields)
+		}
+		body.WriteString("} ")
+	}
+	return fmt.Sprintf("query(%s) { %s}", strings.TrimSuffix(params.String(), ", "), body.String()), variables
+}
+
+func parseRepoSnapshot(raw json.RawMessage, branches []string) (*repoSnapshot, error) {
+	var repo graphQLRepository
+	if err := json.Unmarshal(raw, &repo); err != nil {
+		return nil, err
+	}
+	var aliases map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &aliases); err != nil {
+		return nil, err
+	}
+
+	snapshot := &repoSnapshot{tagsComplete: !repo.Tags.PageInfo.HasNextPage, branches: map[string]string{}}
+	for _, node := range repo.Tags.Nodes {
+		sha, ok := node.Target.commit()
+		if !ok {
+			// Unpeelable tags are left to the fallback.
+			snapshot.tagsComplete = false
+			continue
+		}
+		snapshot.tags = append(snapshot.tags, Tag{Name: node.Name, SHA: sha})
+	}
+	if repo.LatestRelease != nil {
+		snapshot.latestRelease = repo.LatestRelease.TagName
+	}
+	for j, branch := range branches {
+		rawBranch, ok := aliases[fmt.Sprintf("b%d", j)]
+		if !ok || string(rawBranch) == "null" {
+			continue
+		}
+		var ref graphQLBranch
+		if err := json.Unmarshal(rawBranch, &ref); err != nil {
+			return nil, err
+		}
+		if sha, ok := ref.Target.commit(); ok {
+			snapshot.branches[branch] = sha
+		}
+	}
+	return snapshot, nil
+}
+
+func (r *GraphQLResolver) snapshot(repository string) *repoSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.repos[repository]
+}
+
+func (r *GraphQLResolver) ResolveTag(repository, tag string) (string, error) {
+	if snapshot := r.snapshot(repository); snapshot != nil {
+		for _, t := range snapshot.tags {
+			if t.Name == tag {
+				return t.SHA, nil
+			}
+		}
+	}
+	return r.fallback.ResolveTag(repository, tag)
+}
+
+func (r *GraphQLResolver) ResolveBranch(repository, branch string) (string, error) {
+	if snapshot := r.snapshot(repository); snapshot != nil {
+		if sha, ok := snapshot.branches[branch]; ok {
+			return sha, nil
+		}
+	}
+	return r.fallback.ResolveBranch(repository, branch)
+}
+
+func (r *GraphQLResolver) ListTags(repository string) ([]Tag, error) {
+	if snapshot := r.snapshot(repository); snapshot != nil && snapshot.tagsComplete {
+		return snapshot.tags, nil
+	}
+	return r.fallback.ListTags(repository)
+}
+
+func (r *GraphQLResolver) LatestRelease(repository string) (string, error) {
+	if snapshot := r.snapshot(repository); snapshot != nil && snapshot.latestRelease != "" {
+		return snapshot.latestRelease, nil
+	}
+	return r.fallback.LatestRelease(repository)
+}
+
+func (r *GraphQLResolver) CommitDate(repository, sha string) (time.Time, error) {
+	return r.fallback.CommitDate(repository, sha)
+}
+
+func (r *GraphQLResolver) TagDate(repository, tag string) (time.Time, error) {
+	return r.fallback.TagDate(repository, tag)
+}
+
+func (r *GraphQLResolver) CommitReachable(repository, sha string) (bool, error) {
+	return r.fallback.CommitReachable(repository, sha)
+}
+
+func (r *GraphQLResolver) CommitVerification(repository, sha string) (Verification, error) {
+	return r.fallback.CommitVerification(repository, sha)
+}
+
+func (r *GraphQLResolver) RefContains(repository, ref, sha string) (bool, error) {
+	return r.fallback.RefContains(repository, ref, sha)
+}
+
+func (r *GraphQLResolver) FileContent(repository, path, sha string) ([]byte, error) {
+	return r.fallback.FileContent(repository, path, sha)
+}
@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLResolverPrefetch(t *testing.T) {
+	const (
+		shaLight     = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		shaAnnotated = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		shaTagObject = "cccccccccccccccccccccccccccccccccccccccc"
+		shaMain      = "dddddddddddddddddddddddddddddddddddddddd"
+		shaFallback  = "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+	)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.URL.Path != "/graphql" {
+			t.Errorf("Unexpected request path %s", req.URL.Path)
+		}
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Fatalf("Unexpected error decoding request: %v", err)
+		}
+		if body.Variables["o0"] != "actions" || body.Variables["n0"] != "checkout" ||
+			body.Variables["b0_0"] != "refs/heads/main" || body.Variables["n1"] != "missing" {
+			t.Errorf("Unexpected variables %v", body.Variables)
+		}
+		if !strings.Contains(body.Query, "r1: repository(owner: $o1, name: $n1)") {
+			t.Errorf("Query does not alias the second repository: %s", body.Query)
+		}
+		writeJSON(w, http.StatusOK, `{
+			"data": {
+				"r0": {
+					"tags": {
+						"pageInfo": {"hasNextPage": false},
+						"nodes": [
+							{"name": "v4.2.2", "target": {"__typename": "Commit", "oid": "`+shaLight+`"}},
+							{"name": "v4.1.7", "target": {"__typename": "Tag", "oid": "`+shaTagObject+`",
+								"target": {"__typename": "Commit", "oid": "`+shaAnnotated+`"}}}
+						]
+					},
+					"latestRelease": {"tagName": "v4.2.2"},
+					"b0": {"target": {"__typename": "Commit", "oid": "`+shaMain+`"}}
+				},
+				"r1": null
+			},
+			"errors": [{"type": "NOT_FOUND", "path": ["r1"], "message": "Could not resolve to a Repository"}]
+		}`)
+	}))
+	defer server.Close()
+
+	fallback := &MemoryResolver{
+		Tags: map[string][]Tag{"actions/missing": {{Name: "v1.0.0", SHA: shaFallback}}},
+	}
+	r, err := NewGraphQLResolver(apiOptions(rewriteTransport{target: mustParseURL(t, server.URL)}), fallback)
+	if err != nil {
+		t.Fatalf("Unexpected error creating resolver: %v", err)
+	}
+	err = r.Prefetch([]RepoRefs{
+		{Repository: "actions/checkout", Branches: []string{"main"}},
+		{Repository: "actions/missing"},
+	})
+	if err != nil {
+		t.Fatalf("Prefetch unexpected error: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Prefetch made %d requests, want 1", requests)
+	}
+
+	if sha, err := r.ResolveTag("actions/checkout", "v4.1.7"); err != nil || sha != shaAnnotated {
+		t.Errorf("ResolveTag annotated = (%q, %v), want (%q, nil)", sha, err, shaAnnotated)
+	}
+	if sha, err := r.ResolveBranch("actions/checkout", "main"); err != nil || sha != shaMain {
+		t.Errorf("ResolveBranch = (%q, %v), want (%q, nil)", sha, err, shaMain)
+	}
+	if tag, err := r.LatestRelease("actions/checkout"); err != nil || tag != "v4.2.2" {
+		t.Errorf("LatestRelease = (%q, %v), want (%q, nil)", tag, err, "v4.2.2")
+	}
+	if tags, err := r.ListTags("actions/checkout"); err != nil || len(tags) != 2 {
+		t.Errorf("ListTags = (%v, %v), want both prefetched tags", tags, err)
+	}
+	// Repositories GitHub could not resolve go to the fallback.
+	if sha, err := r.ResolveTag("actions/missing", "v1.0.0"); err != nil || sha != shaFallback {
+		t.Errorf("ResolveTag via fallback = (%q, %v), want (%q, nil)", sha, err, shaFallback)
+	}
+	if _, err := r.ResolveBranch("actions/checkout", "develop"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveBranch not prefetched error = %v, want ErrNotFound from fallback", err)
+	}
+	if requests != 1 {
+		t.Errorf("lookups made %d requests, want only the prefetch", requests)
+	}
+}
+
+func TestGraphQLResolverIncompleteTagsFallBack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `{"data": {"r0": {
+			"tags": {"pageInfo": {"hasNextPage": true}, "nodes": []},
+			"latestRelease": null
+		}}}`)
+	}))
+	defer server.Close()
+	fallback := &MemoryResolver{
+		Tags:     map[string][]Tag{"owner/repo": {{Name: "v0.1.0", SHA: "ffffffffffffffffffffffffffffffffffffffff"}}},
+		Releases: map[string]string{"owner/repo": "v0.1.0"},
+	}
+	r, err := NewGraphQLResolver(apiOptions(rewriteTransport{target: mustParseURL(t, server.URL)}), fallback)
+	if err != nil {
+		t.Fatalf("Unexpected error creating resolver: %v", err)
+	}
+	if err := r.Prefetch([]RepoRefs{{Repository: "owner/repo"}}); err != nil {
+		t.Fatalf("Prefetch unexpected error: %v", err)
+	}
+	if tags, err := r.ListTags("owner/repo"); err != nil || len(tags) != 1 {
+		t.Errorf("ListTags with more pages = (%v, %v), want fallback tags", tags, err)
+	}
+	if tag, err := r.LatestRelease("owner/repo"); err != nil || tag != "v0.1.0" {
+		t.Errorf("LatestRelease without release = (%q, %v), want fallback", tag, err)
+	}
+}
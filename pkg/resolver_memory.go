@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MemoryResolver is an in-memory Resolver populated from fixtures, used to exercise pinning
+// without talking to GitHub. All maps are keyed by owner/repo, except CommitDates and
+// Verifications which are keyed by commit SHA; commits missing from Verifications are unsigned.
+// History lists the commits reachable from a repository's refs besides the ones its tags and
+// branches point at. Files holds file contents keyed by "<sha>:<path>" within each repository,
+// and TagDates the publication dates of releases and annotated tags keyed by tag name.
+type MemoryResolver struct {
+	Tags          map[string][]Tag
+	Branches      map[string]map[string]string
+	Releases      map[string]string
+	CommitDates   map[string]time.Time
+	Verifications map[string]Verification
+	History       map[string][]string
+	Files         map[string]map[string]string
+	TagDates      map[string]map[string]time.Time
+}
+
+func (r *MemoryResolver) ResolveTag(repository, tag string) (string, error) {
+	for _, t := range r.Tags[repository] {
+		if t.Name == tag {
+			return t.SHA, nil
+		}
+	}
+	return "", fmt.Errorf("tag %s in %s: %w", tag, repository, ErrNotFound)
+}
+
+func (r *MemoryResolver) ResolveBranch(repository, branch string) (string, error) {
+	sha, ok := r.Branches[repository][branch]
+	if !ok {
+		return "", fmt.Errorf("branch %s in %s: %w", branch, repository, ErrNotFound)
+	}
+	return sha, nil
+}
+
+func (r *MemoryResolver) ListTags(repository string) ([]Tag, error) {
+	tags, ok := r.Tags[repository]
+	if !ok {
+		return nil, fmt.Errorf("repository %s: %w", repository, ErrNotFound)
+	}
+	return tags, nil
+}
+
+func (r *MemoryResolver) LatestRelease(repository string) (string, error) {
+	tag, ok := r.Releases[repository]
+	if !ok {
+		return "", fmt.Errorf("latest release of %s: %w", repository, ErrNotFound)
+	}
+	return tag, nil
+}
+
+func (r *MemoryResolver) CommitDate(repository, sha string) (time.Time, error) {
+	date, ok := r.CommitDates[sha]
+	if !ok {
+		return time.Time{}, fmt.Errorf("commit %s in %s: %w", sha, repository, ErrNotFound)
+	}
+	return date, nil
+}
+
+func (r *MemoryResolver) TagDate(repository, tag string) (time.Time, error) {
+	date, ok := r.TagDates[repository][tag]
+	if !ok {
+		return time.Time{}, fmt.Errorf("release or annotated tag %s in %s: %w", tag, repository, ErrNotFound)
+	}
+	return date, nil
+}
+
+// CommitReachable runs the same ref-by-ref check as the GitHub resolvers, so a repository with
+// more refs than the compare limit leaves an impostor unverified. Every ref is taken to contain
+// the commits in History.
+func (r *MemoryResolver) CommitReachable(repository, sha string) (bool, error) {
+	var branches []Tag
+	for name, head := range r.Branches[repository] {
+		branches = append(branches, Tag{Name: name, SHA: head})
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
+	return commitReachable(sha, "", branches, r.Tags[repository], func(string) (string, error) {
+		for _, commit := range r.History[repository] {
+			if commit == sha {
+				return "behind", nil
+			}
+		}
+		return "diverged", nil
+	})
+}
+
+func (r *MemoryResolver) CommitVerification(repository, sha string) (Verification, error) {
+	if verification, ok := r.Verifications[sha]; ok {
+		return verification, nil
+	}
+	return Verification{Reason: "unsigned"}, nil
+}
+
+func (r *MemoryResolver) FileContent(repository, path, sha string) ([]byte, error) {
+	content, ok := r.Files[repository][sha+":"+path]
+	if !ok {
+		return nil, fmt.Errorf("%s at %s in %s: %w", path, sha, repository, ErrNotFound)
+	}
+	return []byte(content), nil
+}
+
+// RefContains reports whether ref points at sha or sha is in the repository's History, which
+// the fixture treats as reachable from every ref.
+func (r *MemoryResolver) RefContains(repository, ref, sha string) (bool, error) {
+	head, err := r.ResolveBranch(repository, ref)
+	if err != nil {
+		if head, err = r.ResolveTag(repository, ref); err != nil {
+			return false, err
+		}
+	}
+	if head == sha {
+		return true, nil
+	}
+	for _, commit := range r.History[repository] {
+		if commit == sha {
+			return true, nil
+		}
+	}
+	return false, nil
+}
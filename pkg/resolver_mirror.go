@@ -0,0 +1,223 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MirrorResolver resolves refs offline from local git repositories laid out as
+// <Dir>/<owner>/<repo>.git (bare mirrors) or <Dir>/<owner>/<repo> (clones), so pinning works
+// on hosts without GitHub access. Releases are not part of git, so the latest release is the
+// highest semver tag.
+type MirrorResolver struct {
+	Dir string
+}
+
+// NewMirrorResolver returns a Resolver reading the mirrors under dir.
+func NewMirrorResolver(dir string) *MirrorResolver {
+	return &MirrorResolver{Dir: dir}
+}
+
+func (r *MirrorResolver) ResolveTag(repository, tag string) (string, error) {
+	sha, err := r.revParse(repository, "refs/tags/"+tag)
+	if err != nil {
+		return "", fmt.Errorf("tag %s in %s: %w", tag, repository, err)
+	}
+	return sha, nil
+}
+
+// ResolveBranch looks the branch up under refs/heads, as in a bare mirror, falling back to
+// the origin remote-tracking branch of a regular clone.
+func (r *MirrorResolver) ResolveBranch(repository, branch string) (string, error) {
+	sha, err := r.revParse(repository, "refs/heads/"+branch)
+	if err == nil {
+		return sha, nil
+	}
+	if sha, remoteErr := r.revParse(repository, "refs/remotes/origin/"+branch); remoteErr == nil {
+		return sha, nil
+	}
+	return "", fmt.Errorf("branch %s in %s: %w", branch, repository, err)
+}
+
+func (r *MirrorResolver) ListTags(repository string) ([]Tag, error) {
+	// %(*objectname) is the peeled commit of an annotated tag and empty for lightweight tags.
+	out, err := r.git(repository, "for-each-ref", "--format=%(refname:strip=2) %(objectname) %(*objectname)", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	var tags []Tag
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		tag := Tag{Name: fields[0], SHA: fields[1]}
+		if len(fields) == 3 {
+			tag.SHA = fields[2]
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (r *MirrorResolver) LatestRelease(repository string) (string, error) {
+	tags, err := r.ListTags(repository)
+	if err != nil {
+		return "", err
+	}
+	tag, err := HighestVersionTag(TagNames(tags))
+	if err != nil {
+		return "", fmt.Errorf("latest release of %s: %w", repository, ErrNotFound)
+	}
+	return tag, nil
+}
+
+func (r *MirrorResolver) CommitDate(repository, sha string) (time.Time, error) {
+	if _, err := r.revParse(repository, sha); err != nil {
+		return time.Time{}, fmt.Errorf("commit %s in %s: %w", sha, repository, err)
+	}
+	out, err := r.git(repository, "show", "-s", "--format=%cI", sha)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(out))
+}
+
+// TagDate returns the tagger date of an annotated tag. A mirror holds no releases, so
+// lightweight tags return ErrNotFound.
+func (r *MirrorResolver) TagDate(repository, tag string) (time.Time, error) {
+	out, err := r.git(repository, "for-each-ref", "--format=%(taggerdate:iso-strict)", "refs/tags/"+tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	date := strings.TrimSpace(out)
+	if date == "" {
+		return time.Time{}, fmt.Errorf("annotated tag %s in %s: %w", tag, repository, ErrNotFound)
+	}
+	return time.Parse(time.RFC3339, date)
+}
+
+// signatureReasons maps git's %G? signature status to the reasons GitHub reports.
+var signatureReasons = map[string]string{
+	"G": "valid",
+	"U": "unknown_key",
+	"E": "unknown_key",
+	"X": "expired_key",
+	"Y": "expired_key",
+	"R": "invalid",
+	"B": "invalid",
+	"N": "unsigned",
+}
+
+// CommitVerification checks the commit's signature against the local git and GnuPG trust
+// configuration, since a mirror has no record of how GitHub verified it.
+func (r *MirrorResolver) CommitVerification(repository, sha string) (Verification, error) {
+	if _, err := r.revParse(repository, sha); err != nil {
+		return Verification{}, fmt.Errorf("commit %s in %s: %w", sha, repository, err)
+	}
+	out, err := r.git(repository, "show", "-s", "--format=%G?", sha)
+	if err != nil {
+		return Verification{}, err
+	}
+	status := strings.TrimSpace(out)
+	reason, ok := signatureReasons[status]
+	if !ok {
+		reason = "unknown_signature_type"
+	}
+	return Verification{Verified: status == "G", Reason: reason}, nil
+}
+
+func (r *MirrorResolver) FileContent(repository, path, sha string) ([]byte, error) {
+	if _, err := r.revParse(repository, sha); err != nil {
+		return nil, fmt.Errorf("commit %s in %s: %w", sha, repository, err)
+	}
+	if _, err := r.git(repository, "cat-file", "-e", sha+":"+path); err != nil {
+		return nil, fmt.Errorf("%s at %s in %s: %w", path, sha, repository, ErrNotFound)
+	}
+	out, err := r.git(repository, "cat-file", "blob", sha+":"+path)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// CommitReachable reports whether a branch or tag of the mirror contains sha. Commits missing
+// from the mirror are unreachable, since a mirror only holds what its refs reach.
+func (r *MirrorResolver) CommitReachable(repository, sha string) (bool, error) {
+	if _, err := r.repoPath(repository); err != nil {
+		return false, err
+	}
+	if _, err := r.revParse(repository, sha); err != nil {
+		return false, nil
+	}
+	out, err := r.git(repository, "for-each-ref", "--count=1", "--contains", sha, "--format=%(refname)", "refs/heads", "refs/tags", "refs/remotes/origin")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+// RefContains resolves ref as a branch, then as a tag, and asks git whether sha is its ancestor.
+func (r *MirrorResolver) RefContains(repository, ref, sha string) (bool, error) {
+	head, err := r.ResolveBranch(repository, ref)
+	if err != nil {
+		if head, err = r.ResolveTag(repository, ref); err != nil {
+			return false, err
+		}
+	}
+	if _, err := r.revParse(repository, sha); err != nil {
+		return false, nil
+	}
+	// merge-base --is-ancestor exits with status 1 when sha is not an ancestor.
+	_, err = r.git(repository, "merge-base", "--is-ancestor", sha, head)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// revParse resolves ref to the commit it ultimately points at.
+func (r *MirrorResolver) revParse(repository, ref string) (string, error) {
+	out, err := r.git(repository, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", ErrNotFound
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// git runs a git command inside the mirror of repository.
+func (r *MirrorResolver) git(repository string, args ...string) (string, error) {
+	path, err := r.repoPath(repository)
+	if err != nil {
+		return "", err
+	}
+	var stdOut, stdErr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", path}, args...)...)
+	cmd.Stdout = &stdOut
+	cmd.Stderr = &stdErr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s in %s: %w: %s", args[0], path, err, strings.TrimSpace(stdErr.String()))
+	}
+	return stdOut.String(), nil
+}
+
+// repoPath returns the directory holding the mirror of repository.
+func (r *MirrorResolver) repoPath(repository string) (string, error) {
+	if strings.Contains(repository, "..") {
+		return "", fmt.Errorf("invalid repository %q", repository)
+	}
+	base := filepath.Join(r.Dir, filepath.FromSlash(repository))
+	for _, candidate := range []string{base + ".git", base} {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("mirror of %s under %s: %w", repository, r.Dir, ErrNotFound)
+}
@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runGit runs git in dir with a fixed identity and returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newTestMirror builds <dir>/owner/repo.git with two commits, the second adding action.yml, a
+// lightweight and an annotated tag, and returns the mirror root together with both commit SHAs.
+func newTestMirror(t *testing.T) (string, string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	work := t.TempDir()
+	runGit(t, work, "init", "-q", "-b", "main")
+	runGit(t, work, "commit", "-q", "--allow-empty", "-m", "first")
+	first := runGit(t, work, "rev-parse", "HEAD")
+	runGit(t, work, "tag", "v1.0.0")
+	if err := os.WriteFile(filepath.Join(work, "action.yml"), []byte("name: test\n"), 0644); err != nil {
+		t.Fatalf("Unexpected error writing action.yml: %v", err)
+	}
+	runGit(t, work, "add", "action.yml")
+	runGit(t, work, "commit", "-q", "-m", "second")
+	second := runGit(t, work, "rev-parse", "HEAD")
+	runGit(t, work, "tag", "-a", "v1.10.0", "-m", "annotated")
+	runGit(t, work, "tag", "not-a-version")
+
+	mirrors := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(mirrors, "owner"), 0755); err != nil {
+		t.Fatalf("Unexpected error creating mirror dir: %v", err)
+	}
+	runGit(t, mirrors, "clone", "-q", "--mirror", work, filepath.Join("owner", "repo.git"))
+	return mirrors, first, second
+}
+
+func TestMirrorResolver(t *testing.T) {
+	dir, first, second := newTestMirror(t)
+	r := NewMirrorResolver(dir)
+
+	if sha, err := r.ResolveTag("owner/repo", "v1.0.0"); err != nil || sha != first {
+		t.Errorf("ResolveTag lightweight = (%q, %v), want (%q, nil)", sha, err, first)
+	}
+	if sha, err := r.ResolveTag("owner/repo", "v1.10.0"); err != nil || sha != second {
+		t.Errorf("ResolveTag annotated = (%q, %v), want (%q, nil)", sha, err, second)
+	}
+	if _, err := r.ResolveTag("owner/repo", "v9.9.9"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveTag missing error = %v, want ErrNotFound", err)
+	}
+	if sha, err := r.ResolveBranch("owner/repo", "main"); err != nil || sha != second {
+		t.Errorf("ResolveBranch = (%q, %v), want (%q, nil)", sha, err, second)
+	}
+	if _, err := r.ResolveBranch("owner/missing", "main"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveBranch in missing mirror error = %v, want ErrNotFound", err)
+	}
+
+	tags, err := r.ListTags("owner/repo")
+	if err != nil {
+		t.Fatalf("ListTags unexpected error: %v", err)
+	}
+	for _, tag := range tags {
+		if tag.Name == "v1.10.0" && tag.SHA != second {
+			t.Errorf("ListTags annotated tag SHA = %q, want peeled commit %q", tag.SHA, second)
+		}
+	}
+	if len(tags) != 3 {
+		t.Errorf("ListTags returned %d tags, want 3", len(tags))
+	}
+
+	if tag, err := r.LatestRelease("owner/repo"); err != nil || tag != "v1.10.0" {
+		t.Errorf("LatestRelease = (%q, %v), want (%q, nil)", tag, err, "v1.10.0")
+	}
+
+	if date, err := r.CommitDate("owner/repo", first); err != nil || time.Since(date) > time.Hour {
+		t.Errorf("CommitDate = (%v, %v), want the fixture's recent commit date", date, err)
+	}
+	if _, err := r.CommitDate("owner/repo", strings.Repeat("0", 40)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CommitDate missing error = %v, want ErrNotFound", err)
+	}
+	if date, err := r.TagDate("owner/repo", "v1.10.0"); err != nil || time.Since(date) > time.Hour {
+		t.Errorf("TagDate(annotated) = (%v, %v), want the fixture's recent tagger date", date, err)
+	}
+	if _, err := r.TagDate("owner/repo", "v1.0.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("TagDate(lightweight) error = %v, want ErrNotFound", err)
+	}
+
+	if v, err := r.CommitVerification("owner/repo", first); err != nil || v != (Verification{Reason: "unsigned"}) {
+		t.Errorf("CommitVerification = (%+v, %v), want unsigned", v, err)
+	}
+	if _, err := r.CommitVerification("owner/repo", strings.Repeat("0", 40)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CommitVerification missing error = %v, want ErrNotFound", err)
+	}
+
+	// A commit object no branch or tag reaches, as a fork's commit would be.
+	mirror := filepath.Join(dir, "owner", "repo.git")
+	dangling := runGit(t, mirror, "commit-tree", "-p", second, "-m", "dangling", second+"^{tree}")
+	tests := []struct {
+		sha  string
+		want bool
+	}{
+		{sha: first, want: true},
+		{sha: second, want: true},
+		{sha: dangling, want: false},
+		{sha: strings.Repeat("0", 40), want: false},
+	}
+	for _, test := range tests {
+		if got, err := r.CommitReachable("owner/repo", test.sha); err != nil || got != test.want {
+			t.Errorf("CommitReachable(%s) = (%v, %v), want (%v, nil)", test.sha, got, err, test.want)
+		}
+	}
+	if _, err := r.CommitReachable("owner/missing", first); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CommitReachable in missing mirror error = %v, want ErrNotFound", err)
+	}
+
+	containsTests := []struct {
+		ref  string
+		sha  string
+		want bool
+	}{
+		{ref: "main", sha: second, want: true},
+		{ref: "main", sha: first, want: true},
+		{ref: "v1.0.0", sha: second, want: false},
+		{ref: "main", sha: dangling, want: false},
+	}
+	for _, test := range containsTests {
+		if got, err := r.RefContains("owner/repo", test.ref, test.sha); err != nil || got != test.want {
+			t.Errorf("RefContains(%s, %s) = (%v, %v), want (%v, nil)", test.ref, test.sha, got, err, test.want)
+		}
+	}
+	if _, err := r.RefContains("owner/repo", "missing", first); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RefContains for missing ref error = %v, want ErrNotFound", err)
+	}
+
+	if content, err := r.FileContent("owner/repo", "action.yml", second); err != nil || string(content) != "name: test\n" {
+		t.Errorf("FileContent = (%q, %v), want the committed action.yml", content, err)
+	}
+	if _, err := r.FileContent("owner/repo", "action.yml", first); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FileContent before the file existed error = %v, want ErrNotFound", err)
+	}
+}
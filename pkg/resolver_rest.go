@@ -0,0 +1,343 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+)
+
+const (
+	// tagsPageSize is the largest page GitHub serves for list endpoints.
+	tagsPageSize = 100
+	// maxTagPeelDepth bounds how many annotated tag objects are followed to reach a commit.
+	maxTagPeelDepth = 10
+)
+
+var linkNextRegexp = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// RESTResolver resolves refs through the GitHub REST API using go-gh's client, which picks up
+// the same host and credentials as the gh CLI.
+type RESTResolver struct {
+	client *api.RESTClient
+}
+
+// NewRESTResolver returns a Resolver backed by the GitHub REST API. Zero-valued opts resolve
+// the host and token from the gh environment. Requests are retried on rate limits; pass a
+// *RetryTransport as opts.Transport to tune the policy.
+func NewRESTResolver(opts api.ClientOptions) (*RESTResolver, error) {
+	if _, ok := opts.Transport.(*RetryTransport); !ok {
+		opts.Transport = NewRetryTransport(opts.Transport)
+	}
+	client, err := api.NewRESTClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &RESTResolver{client: client}, nil
+}
+
+type restTag struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+// restObject is the git object a ref or annotated tag points to.
+type restObject struct {
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+type restRef struct {
+	Ref    string     `json:"ref"`
+	Object restObject `json:"object"`
+}
+
+type restAnnotatedTag struct {
+	Object restObject `json:"object"`
+	Tagger struct {
+		Date time.Time `json:"date"`
+	} `json:"tagger"`
+}
+
+type restBranch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+type restRelease struct {
+	TagName     string    `json:"tag_name"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+type restContent struct {
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+type restRepository struct {
+	DefaultBranch string `json:"default_branch"`
+}
+
+type restComparison struct {
+	Status string `json:"status"`
+}
+
+type restCommit struct {
+	Commit struct {
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+		Verification struct {
+			Verified bool   `json:"verified"`
+			Reason   string `json:"reason"`
+		} `json:"verification"`
+	} `json:"commit"`
+}
+
+// ResolveTag looks refs/tags/<tag> up directly and peels annotated tags, so the returned SHA
+// is always a commit and never a tag object.
+func (r *RESTResolver) ResolveTag(repository, tag string) (string, error) {
+	var refs []restRef
+	if err := r.get(fmt.Sprintf("repos/%s/git/matching-refs/tags/%s", repository, escapeRefPath(tag)), &refs); err != nil {
+		return "", err
+	}
+	// matching-refs is a prefix match (v1 also returns v1.0.0), so pick the exact ref.
+	for _, ref := range refs {
+		if ref.Ref == "refs/tags/"+tag {
+			return r.peelTag(repository, tag, ref.Object)
+		}
+	}
+	return "", fmt.Errorf("tag %s in %s: %w", tag, repository, ErrNotFound)
+}
+
+// peelTag follows annotated tag objects until it reaches the commit they point at.
+func (r *RESTResolver) peelTag(repository, tag string, object restObject) (string, error) {
+	for depth := 0; depth < maxTagPeelDepth; depth++ {
+		switch object.Type {
+		case "commit":
+			return object.SHA, nil
+		case "tag":
+			var annotated restAnnotatedTag
+			if err := r.get(fmt.Sprintf("repos/%s/git/tags/%s", repository, object.SHA), &annotated); err != nil {
+				return "", err
+			}
+			object = annotated.Object
+		default:
+			return "", fmt.Errorf("tag %s in %s points to a %s, not a commit", tag, repository, object.Type)
+		}
+	}
+	return "", fmt.Errorf("tag %s in %s is nested more than %d tags deep", tag, repository, maxTagPeelDepth)
+}
+
+func (r *RESTResolver) ResolveBranch(repository, branch string) (string, error) {
+	var resp restBranch
+	if err := r.get(fmt.Sprintf("repos/%s/branches/%s", repository, escapeRefPath(branch)), &resp); err != nil {
+		return "", err
+	}
+	return resp.Commit.SHA, nil
+}
+
+// ListTags returns every tag of the repository, following pagination so repositories with
+// more than one page of tags can still resolve their oldest versions.
+func (r *RESTResolver) ListTags(repository string) ([]Tag, error) {
+	var tags []Tag
+	path := fmt.Sprintf("repos/%s/tags?per_page=%d", repository, tagsPageSize)
+	err := r.getPages(path, func(dec *json.Decoder) error {
+		var page []restTag
+		if err := dec.Decode(&page); err != nil {
+			return err
+		}
+		for _, t := range page {
+			tags = append(tags, Tag{Name: t.Name, SHA: t.Commit.SHA})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *RESTResolver) LatestRelease(repository string) (string, error) {
+	var resp restRelease
+	if err := r.get(fmt.Sprintf("repos/%s/releases/latest", repository), &resp); err != nil {
+		return "", err
+	}
+	return resp.TagName, nil
+}
+
+func (r *RESTResolver) CommitDate(repository, sha string) (time.Time, error) {
+	var resp restCommit
+	if err := r.get(fmt.Sprintf("repos/%s/commits/%s", repository, sha), &resp); err != nil {
+		return time.Time{}, err
+	}
+	return resp.Commit.Committer.Date, nil
+}
+
+// TagDate reads the publication date of the tag's release, falling back to the tagger date
+// when the tag has no published release and is annotated.
+func (r *RESTResolver) TagDate(repository, tag string) (time.Time, error) {
+	var release restRelease
+	err := r.get(fmt.Sprintf("repos/%s/releases/tags/%s", repository, escapeRefPath(tag)), &release)
+	if err == nil && !release.PublishedAt.IsZero() {
+		return release.PublishedAt, nil
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return time.Time{}, err
+	}
+	var refs []restRef
+	if err := r.get(fmt.Sprintf("repos/%s/git/matching-refs/tags/%s", repository, escapeRefPath(tag)), &refs); err != nil {
+		return time.Time{}, err
+	}
+	for _, ref := range refs {
+		if ref.Ref != "refs/tags/"+tag || ref.Object.Type != "tag" {
+			continue
+		}
+		var annotated restAnnotatedTag
+		if err := r.get(fmt.Sprintf("repos/%s/git/tags/%s", repository, ref.Object.SHA), &annotated); err != nil {
+			return time.Time{}, err
+		}
+		if !annotated.Tagger.Date.IsZero() {
+			return annotated.Tagger.Date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("release or annotated tag %s in %s: %w", tag, repository, ErrNotFound)
+}
+
+func (r *RESTResolver) CommitVerification(repository, sha string) (Verification, error) {
+	var resp restCommit
+	if err := r.get(fmt.Sprintf("repos/%s/commits/%s", repository, sha), &resp); err != nil {
+		return Verification{}, err
+	}
+	return Verification{Verified: resp.Commit.Verification.Verified, Reason: resp.Commit.Verification.Reason}, nil
+}
+
+func (r *RESTResolver) FileContent(repository, path, sha string) ([]byte, error) {
+	var resp restContent
+	if err := r.get(fmt.Sprintf("repos/%s/contents/%s?ref=%s", repository, escapeRefPath(path), sha), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Type != "file" || resp.Encoding != "base64" {
+		return nil, fmt.Errorf("%s at %s in %s is not a file: %w", path, sha, repository, ErrNotFound)
+	}
+	return decodeContent(resp.Content)
+}
+
+// decodeContent decodes file content from the contents API, which is base64 broken into lines.
+func decodeContent(content string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.ReplaceAll(content, "\n", ""))
+}
+
+// CommitReachable looks for a branch or tag containing sha, first among the refs pointing at it
+// and then through the compare API. Commits GitHub doesn't know at all are unreachable.
+func (r *RESTResolver) CommitReachable(repository, sha string) (bool, error) {
+	if _, err := r.CommitDate(repository, sha); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	var repo restRepository
+	if err := r.get(fmt.Sprintf("repos/%s", repository), &repo); err != nil {
+		return false, err
+	}
+	branches, err := r.listBranches(repository)
+	if err != nil {
+		return false, err
+	}
+	tags, err := r.ListTags(repository)
+	if err != nil {
+		return false, err
+	}
+	return commitReachable(sha, repo.DefaultBranch, branches, tags, func(ref string) (string, error) {
+		return r.compareStatus(repository, ref, sha)
+	})
+}
+
+func (r *RESTResolver) RefContains(repository, ref, sha string) (bool, error) {
+	status, err := r.compareStatus(repository, ref, sha)
+	if err != nil {
+		return false, err
+	}
+	return status == "behind" || status == "identical", nil
+}
+
+// compareStatus returns the status of comparing head against base: "identical", "behind"
+// when base contains head, "ahead" or "diverged".
+func (r *RESTResolver) compareStatus(repository, base, head string) (string, error) {
+	var resp restComparison
+	err := r.get(fmt.Sprintf("repos/%s/compare/%s...%s?per_page=1", repository, escapeRefPath(base), head), &resp)
+	return resp.Status, err
+}
+
+// listBranches returns the branches of the repository as name and head commit pairs.
+func (r *RESTResolver) listBranches(repository string) ([]Tag, error) {
+	var branches []Tag
+	path := fmt.Sprintf("repos/%s/branches?per_page=%d", repository, tagsPageSize)
+	err := r.getPages(path, func(dec *json.Decoder) error {
+		var page []restBranch
+		if err := dec.Decode(&page); err != nil {
+			return err
+		}
+		for _, b := range page {
+			branches = append(branches, Tag{Name: b.Name, SHA: b.Commit.SHA})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+func (r *RESTResolver) get(path string, resp interface{}) error {
+	return classifyHTTPError(r.client.Get(path, resp))
+}
+
+// getPages requests path and every page after it linked through the Link header, handing
+// each response body to decode.
+func (r *RESTResolver) getPages(path string, decode func(*json.Decoder) error) error {
+	for path != "" {
+		resp, err := r.client.Request(http.MethodGet, path, nil)
+		if err != nil {
+			return classifyHTTPError(err)
+		}
+		err = decode(json.NewDecoder(resp.Body))
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		path = nextPageURL(resp.Header.Get("Link"))
+	}
+	return nil
+}
+
+// escapeRefPath escapes each path segment of a ref name, keeping the slashes of names like
+// release/v1 intact. File paths in the contents API are escaped the same way.
+func escapeRefPath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// nextPageURL returns the rel="next" URL of a Link header, or "" on the last page.
+func nextPageURL(link string) string {
+	if m := linkNextRegexp.FindStringSubmatch(link); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,363 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+)
+
+// rewriteTransport sends every request to target, keeping the path and query.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing URL %s: %v", rawURL, err)
+	}
+	return u
+}
+
+// apiOptions returns client options for github.com that send requests through transport.
+func apiOptions(transport http.RoundTripper) api.ClientOptions {
+	return api.ClientOptions{Host: "github.com", AuthToken: "test-token", Transport: transport}
+}
+
+// newTestRESTResolver returns a RESTResolver talking to a test server serving handler.
+// Rate-limit retries happen without waiting.
+func newTestRESTResolver(t *testing.T, handler http.Handler) *RESTResolver {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	transport := NewRetryTransport(rewriteTransport{target: mustParseURL(t, server.URL)})
+	transport.sleep = func(context.Context, time.Duration) error { return nil }
+	r, err := NewRESTResolver(apiOptions(transport))
+	if err != nil {
+		t.Fatalf("Unexpected error creating resolver: %v", err)
+	}
+	return r
+}
+
+func writeJSON(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
+
+func TestRESTResolver(t *testing.T) {
+	const (
+		shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/actions/checkout/tags", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[{"name":"v4.2.2","commit":{"sha":"`+shaA+`"}}]`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/git/matching-refs/tags/v4.2.2", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[{"ref":"refs/tags/v4.2.2","object":{"type":"commit","sha":"`+shaA+`"}}]`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/git/matching-refs/tags/v9.9.9", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[]`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/branches/main", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `{"name":"main","commit":{"sha":"`+shaB+`"}}`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `{"tag_name":"v4.2.2"}`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/commits/"+shaA, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `{"sha":"`+shaA+`","commit":{"committer":{"date":"2026-03-01T12:00:00Z"},"verification":{"verified":true,"reason":"valid"}}}`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/contents/action.yml", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("ref") != shaA {
+			writeJSON(w, http.StatusNotFound, `{"message":"Not Found"}`)
+			return
+		}
+		// "name: checkout\n" in base64, wrapped the way the contents API wraps it.
+		writeJSON(w, http.StatusOK, `{"type":"file","encoding":"base64","content":"bmFtZTog\nY2hlY2tvdXQK\n"}`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/contents/dist", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[{"type":"file","name":"index.js"}]`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/releases/tags/", func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/v4.2.2") {
+			writeJSON(w, http.StatusOK, `{"tag_name":"v4.2.2","published_at":"2026-04-02T08:00:00Z"}`)
+			return
+		}
+		writeJSON(w, http.StatusNotFound, `{"message":"Not Found"}`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/git/matching-refs/tags/v4.1.0", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[{"ref":"refs/tags/v4.1.0","object":{"type":"tag","sha":"`+shaB+`"}}]`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/git/tags/"+shaB, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `{"object":{"type":"commit","sha":"`+shaA+`"},"tagger":{"date":"2026-03-15T09:30:00Z"}}`)
+	})
+	r := newTestRESTResolver(t, mux)
+
+	if sha, err := r.ResolveTag("actions/checkout", "v4.2.2"); err != nil || sha != shaA {
+		t.Errorf("ResolveTag = (%q, %v), want (%q, nil)", sha, err, shaA)
+	}
+	if _, err := r.ResolveTag("actions/checkout", "v9.9.9"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveTag for missing tag error = %v, want ErrNotFound", err)
+	}
+	if sha, err := r.ResolveBranch("actions/checkout", "main"); err != nil || sha != shaB {
+		t.Errorf("ResolveBranch = (%q, %v), want (%q, nil)", sha, err, shaB)
+	}
+	if tag, err := r.LatestRelease("actions/checkout"); err != nil || tag != "v4.2.2" {
+		t.Errorf("LatestRelease = (%q, %v), want (%q, nil)", tag, err, "v4.2.2")
+	}
+	want := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if date, err := r.CommitDate("actions/checkout", shaA); err != nil || !date.Equal(want) {
+		t.Errorf("CommitDate = (%v, %v), want (%v, nil)", date, err, want)
+	}
+	if _, err := r.CommitDate("actions/checkout", shaB); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CommitDate for missing commit error = %v, want ErrNotFound", err)
+	}
+	// Release publication dates win over tagger dates; lightweight tags without a release have neither.
+	wantTagDates := map[string]time.Time{
+		"v4.2.2": time.Date(2026, 4, 2, 8, 0, 0, 0, time.UTC),
+		"v4.1.0": time.Date(2026, 3, 15, 9, 30, 0, 0, time.UTC),
+	}
+	for tag, want := range wantTagDates {
+		if date, err := r.TagDate("actions/checkout", tag); err != nil || !date.Equal(want) {
+			t.Errorf("TagDate(%s) = (%v, %v), want (%v, nil)", tag, date, err, want)
+		}
+	}
+	if _, err := r.TagDate("actions/checkout", "v9.9.9"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("TagDate for a tag without a release or annotation error = %v, want ErrNotFound", err)
+	}
+	if v, err := r.CommitVerification("actions/checkout", shaA); err != nil || v != (Verification{Verified: true, Reason: "valid"}) {
+		t.Errorf("CommitVerification = (%+v, %v), want verified", v, err)
+	}
+	if content, err := r.FileContent("actions/checkout", "action.yml", shaA); err != nil || string(content) != "name: checkout\n" {
+		t.Errorf("FileContent = (%q, %v), want the decoded action.yml", content, err)
+	}
+	if _, err := r.FileContent("actions/checkout", "action.yml", shaB); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FileContent at a commit without the file error = %v, want ErrNotFound", err)
+	}
+	if _, err := r.FileContent("actions/checkout", "dist", shaA); err == nil {
+		t.Errorf("Expected error for FileContent of a directory")
+	}
+}
+
+func TestRESTResolverErrorClassification(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  map[string]string
+		body    string
+		wantErr error
+	}{
+		{name: "not found", status: http.StatusNotFound, body: `{"message":"Not Found"}`, wantErr: ErrNotFound},
+		{name: "bad credentials", status: http.StatusUnauthorized, body: `{"message":"Bad credentials"}`, wantErr: ErrUnauthorized},
+		{name: "primary rate limit", status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0"}, body: `{"message":"API rate limit exceeded"}`, wantErr: ErrRateLimited},
+		{name: "secondary rate limit", status: http.StatusForbidden, body: `{"message":"You have exceeded a secondary rate limit"}`, wantErr: ErrRateLimited},
+		{name: "too many requests", status: http.StatusTooManyRequests, body: `{"message":"Too Many Requests"}`, wantErr: ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRESTResolver(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				writeJSON(w, tt.status, tt.body)
+			}))
+			_, err := r.LatestRelease("actions/checkout")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("LatestRelease error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRESTResolverPaginatesTags(t *testing.T) {
+	const (
+		shaNew = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		shaOld = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	r := newTestRESTResolver(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("per_page") != "100" {
+			t.Errorf("Expected per_page=100, got query %q", req.URL.RawQuery)
+		}
+		switch req.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", `<https://api.github.com/repos/actions/setup-node/tags?per_page=100&page=2>; rel="next", <https://api.github.com/repos/actions/setup-node/tags?per_page=100&page=2>; rel="last"`)
+			writeJSON(w, http.StatusOK, `[{"name":"v4.0.0","commit":{"sha":"`+shaNew+`"}}]`)
+		case "2":
+			writeJSON(w, http.StatusOK, `[{"name":"v2.5.1","commit":{"sha":"`+shaOld+`"}}]`)
+		default:
+			t.Errorf("Unexpected page request %q", req.URL.RawQuery)
+		}
+	}))
+
+	tags, err := r.ListTags("actions/setup-node")
+	if err != nil {
+		t.Fatalf("ListTags unexpected error: %v", err)
+	}
+	if len(tags) != 2 || tags[1] != (Tag{Name: "v2.5.1", SHA: shaOld}) {
+		t.Errorf("ListTags = %v, want both pages", tags)
+	}
+}
+
+func TestRESTResolverPeelsAnnotatedTags(t *testing.T) {
+	const (
+		shaCommit = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		shaOuter  = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		shaInner  = "cccccccccccccccccccccccccccccccccccccccc"
+		shaTree   = "dddddddddddddddddddddddddddddddddddddddd"
+		shaPrefix = "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/git/matching-refs/tags/v1", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[
+			{"ref":"refs/tags/v1","object":{"type":"tag","sha":"`+shaOuter+`"}},
+			{"ref":"refs/tags/v1.0.0","object":{"type":"commit","sha":"`+shaPrefix+`"}}
+		]`)
+	})
+	mux.HandleFunc("/repos/owner/repo/git/tags/"+shaOuter, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `{"sha":"`+shaOuter+`","object":{"type":"tag","sha":"`+shaInner+`"}}`)
+	})
+	mux.HandleFunc("/repos/owner/repo/git/tags/"+shaInner, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `{"sha":"`+shaInner+`","object":{"type":"commit","sha":"`+shaCommit+`"}}`)
+	})
+	mux.HandleFunc("/repos/owner/repo/git/matching-refs/tags/v1.0", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[{"ref":"refs/tags/v1.0.0","object":{"type":"commit","sha":"`+shaPrefix+`"}}]`)
+	})
+	mux.HandleFunc("/repos/owner/repo/git/matching-refs/tags/tree", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[{"ref":"refs/tags/tree","object":{"type":"tree","sha":"`+shaTree+`"}}]`)
+	})
+	r := newTestRESTResolver(t, mux)
+
+	if sha, err := r.ResolveTag("owner/repo", "v1"); err != nil || sha != shaCommit {
+		t.Errorf("ResolveTag annotated = (%q, %v), want (%q, nil)", sha, err, shaCommit)
+	}
+	if _, err := r.ResolveTag("owner/repo", "v1.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveTag with only prefix matches error = %v, want ErrNotFound", err)
+	}
+	if _, err := r.ResolveTag("owner/repo", "tree"); err == nil {
+		t.Errorf("ResolveTag pointing at a tree expected error, got nil")
+	}
+}
+
+func TestRESTResolverEnterpriseHost(t *testing.T) {
+	var gotHost, gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotPath = req.URL.Path
+		gotAuth = req.Header.Get("Authorization")
+		writeJSON(w, http.StatusOK, `{"tag_name":"v1.0.0"}`)
+	}))
+	defer server.Close()
+	target := mustParseURL(t, server.URL)
+	hostRecorder := http.RoundTripper(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		gotHost = req.URL.Host
+		return rewriteTransport{target: target}.RoundTrip(req)
+	}))
+	r, err := NewRESTResolver(api.ClientOptions{Host: "ghes.example.com", AuthToken: "ghes-token", Transport: hostRecorder})
+	if err != nil {
+		t.Fatalf("Unexpected error creating resolver: %v", err)
+	}
+	if _, err := r.LatestRelease("owner/repo"); err != nil {
+		t.Fatalf("LatestRelease unexpected error: %v", err)
+	}
+	if gotHost != "ghes.example.com" || gotPath != "/api/v3/repos/owner/repo/releases/latest" {
+		t.Errorf("request went to %s%s, want ghes.example.com/api/v3/repos/owner/repo/releases/latest", gotHost, gotPath)
+	}
+	if gotAuth != "token ghes-token" {
+		t.Errorf("Authorization = %q, want the enterprise token", gotAuth)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRESTResolverCommitReachable(t *testing.T) {
+	const (
+		shaA     = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		ancestor = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		impostor = "cccccccccccccccccccccccccccccccccccccccc"
+		missing  = "dddddddddddddddddddddddddddddddddddddddd"
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/actions/checkout", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `{"default_branch":"main"}`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/commits/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, missing) {
+			writeJSON(w, http.StatusNotFound, `{"message":"No commit found for SHA"}`)
+			return
+		}
+		writeJSON(w, http.StatusOK, `{"commit":{"committer":{"date":"2026-03-01T12:00:00Z"}}}`)
+	})
+	mux.HandleFunc("/repos/actions/checkout/branches", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, `[{"name":"feature","commit":{"sha":"`+shaA+`"}},{"name":"main","commit":{"sha":"`+shaA+`"}}]`)
+	})
+	tags := `[]`
+	mux.HandleFunc("/repos/actions/checkout/tags", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, tags)
+	})
+	var compares int
+	mux.HandleFunc("/repos/actions/checkout/compare/", func(w http.ResponseWriter, r *http.Request) {
+		compares++
+		status := "diverged"
+		if strings.HasSuffix(r.URL.Path, "main..."+ancestor) {
+			status = "behind"
+		}
+		writeJSON(w, http.StatusOK, `{"status":"`+status+`"}`)
+	})
+	r := newTestRESTResolver(t, mux)
+
+	tests := []struct {
+		sha  string
+		want bool
+	}{
+		{sha: shaA, want: true},
+		{sha: ancestor, want: true},
+		{sha: impostor, want: false},
+		{sha: missing, want: false},
+	}
+	for _, test := range tests {
+		if got, err := r.CommitReachable("actions/checkout", test.sha); err != nil || got != test.want {
+			t.Errorf("CommitReachable(%s) = (%v, %v), want (%v, nil)", test.sha, got, err, test.want)
+		}
+	}
+	compares = 0
+	if got, err := r.CommitReachable("actions/checkout", ancestor); err != nil || !got || compares != 1 {
+		t.Errorf("CommitReachable(ancestor) = (%v, %v) after %d compares, want (true, nil) after comparing the default branch only", got, err, compares)
+	}
+
+	// An impostor in a repository with more refs than the compare limit is unverified.
+	var many []string
+	for i := 0; i < maxReachabilityCompares; i++ {
+		many = append(many, fmt.Sprintf(`{"name":"v1.0.%d","commit":{"sha":"%s"}}`, i, shaA))
+	}
+	tags = "[" + strings.Join(many, ",") + "]"
+	if got, err := r.CommitReachable("actions/checkout", impostor); !errors.Is(err, ErrUnverified) || got {
+		t.Errorf("CommitReachable(impostor) with %d tags = (%v, %v), want (false, ErrUnverified)", len(many), got, err)
+	}
+	if got, err := r.RefContains("actions/checkout", "main", ancestor); err != nil || !got {
+		t.Errorf("RefContains(main, ancestor) = (%v, %v), want (true, nil)", got, err)
+	}
+	if got, err := r.RefContains("actions/checkout", "main", impostor); err != nil || got {
+		t.Errorf("RefContains(main, impostor) = (%v, %v), want (false, nil)", got, err)
+	}
+}
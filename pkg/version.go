@@ -2,74 +2,231 @@ package pkg
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 )
 
+// Semver is a SemVer 2.0 version. Prerelease and Build hold the dot-separated identifiers
+// after the "-" and "+" markers, without the markers.
 type Semver struct {
-	Major int
-	Minor int
-	Patch int
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+	Build      string
 }
 
+var semverIdentifierRegexp = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// ParseSemver parses a SemVer 2.0 version with an optional "v" prefix. A missing patch
+// component is read as 0 (v1.2 is 1.2.0), but a major-only version such as v1 is rejected,
+// since tags like that are conventionally floating aliases rather than releases.
 func ParseSemver(version string) (Semver, error) {
+	original := version
 	version = strings.TrimPrefix(version, "v")
-	parts := strings.Split(version, ".")
-	if len(parts) != 3 {
-		return Semver{}, fmt.Errorf("invalid semver: %s", version)
+	var semver Semver
+	version, build, hasBuild := strings.Cut(version, "+")
+	core, prerelease, hasPrerelease := strings.Cut(version, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Semver{}, fmt.Errorf("invalid semver: %s", original)
 	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := parseNumericIdentifier(part)
+		if err != nil {
+			return Semver{}, fmt.Errorf("invalid semver: %s", original)
+		}
+		numbers[i] = n
+	}
+	semver.Major, semver.Minor, semver.Patch = numbers[0], numbers[1], numbers[2]
 
-	major, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return Semver{}, err
+	if hasPrerelease {
+		for _, identifier := range strings.Split(prerelease, ".") {
+			if !semverIdentifierRegexp.MatchString(identifier) {
+				return Semver{}, fmt.Errorf("invalid semver: %s", original)
+			}
+			if isNumeric(identifier) {
+				if _, err := parseNumericIdentifier(identifier); err != nil {
+					return Semver{}, fmt.Errorf("invalid semver: %s", original)
+				}
+			}
+		}
+		semver.Prerelease = prerelease
 	}
+	if hasBuild {
+		for _, identifier := range strings.Split(build, ".") {
+			if !semverIdentifierRegexp.MatchString(identifier) {
+				return Semver{}, fmt.Errorf("invalid semver: %s", original)
+			}
+		}
+		semver.Build = build
+	}
+	return semver, nil
+}
 
-	minor, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return Semver{}, err
+// parseNumericIdentifier parses a numeric identifier, which must not have leading zeros.
+func parseNumericIdentifier(s string) (int, error) {
+	if !isNumeric(s) || (len(s) > 1 && s[0] == '0') {
+		return 0, fmt.Errorf("invalid numeric identifier: %q", s)
 	}
+	return strconv.Atoi(s)
+}
 
-	patch, err := strconv.Atoi(parts[2])
-	if err != nil {
-		return Semver{}, err
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
 	}
+	return true
+}
 
-	return Semver{Major: major, Minor: minor, Patch: patch}, nil
+// IsPrerelease reports whether s has pre-release identifiers.
+func (s Semver) IsPrerelease() bool {
+	return s.Prerelease != ""
 }
 
-func FindHighestPatchVersion(tags []string, version string) (string, error) {
-	var semverVersion Semver
-	// Remove the last element if it's an empty string
-	if tags[len(tags)-1] == "" {
-		tags = tags[:len(tags)-1]
+// String formats s as major.minor.patch[-prerelease][+build], without a "v" prefix.
+func (s Semver) String() string {
+	version := fmt.Sprintf("%d.%d.%d", s.Major, s.Minor, s.Patch)
+	if s.Prerelease != "" {
+		version += "-" + s.Prerelease
+	}
+	if s.Build != "" {
+		version += "+" + s.Build
 	}
+	return version
+}
 
-	// sort tags in descending order
-	sort.Strings(tags)
-	sort.Slice(tags, func(i, j int) bool {
-		return tags[i] > tags[j]
-	})
+// Compare returns -1, 0 or 1 as s has lower, equal or higher precedence than other, following
+// the SemVer 2.0 rules: numeric components compare numerically, a pre-release sorts before its
+// release, and build metadata is ignored.
+func (s Semver) Compare(other Semver) int {
+	if c := compareInt(s.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(s.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(s.Patch, other.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case s.Prerelease == other.Prerelease:
+		return 0
+	case s.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	ids, otherIDs := strings.Split(s.Prerelease, "."), strings.Split(other.Prerelease, ".")
+	for i := 0; i < len(ids) && i < len(otherIDs); i++ {
+		if c := comparePrereleaseIdentifier(ids[i], otherIDs[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(ids), len(otherIDs))
+}
 
-	for _, tag := range tags {
+// comparePrereleaseIdentifier orders numeric identifiers numerically and below alphanumeric
+// ones, which compare in ASCII order.
+func comparePrereleaseIdentifier(a, b string) int {
+	aNumeric, bNumeric := isNumeric(a), isNumeric(b)
+	switch {
+	case aNumeric && bNumeric:
+		aN, _ := strconv.Atoi(a)
+		bN, _ := strconv.Atoi(b)
+		return compareInt(aN, bN)
+	case aNumeric:
+		return -1
+	case bNumeric:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
 
-		tagVersion, err := ParseSemver(tag)
+// SortVersionTags returns the semver tags among tags ordered from highest to lowest
+// precedence, dropping tags that are not semver. Tags of equal precedence keep the most
+// specific name first, so v1.2.0 sorts ahead of v1.2.
+func SortVersionTags(tags []string) []string {
+	type versionTag struct {
+		name    string
+		version Semver
+	}
+	var versionTags []versionTag
+	for _, tag := range tags {
+		version, err := ParseSemver(tag)
 		if err != nil {
 			continue
 		}
+		versionTags = append(versionTags, versionTag{name: tag, version: version})
+	}
+	sort.SliceStable(versionTags, func(i, j int) bool {
+		if c := versionTags[i].version.Compare(versionTags[j].version); c != 0 {
+			return c > 0
+		}
+		return len(versionTags[i].name) > len(versionTags[j].name)
+	})
+	sorted := make([]string, 0, len(versionTags))
+	for _, tag := range versionTags {
+		sorted = append(sorted, tag.name)
+	}
+	return sorted
+}
+
+// FindHighestPatchVersion returns the highest release tag (pre-releases excluded) within the
+// requested major ("3") or major.minor ("3.1") version, or more generally the highest tag
+// satisfying version as a Constraint.
+func FindHighestPatchVersion(tags []string, version string) (string, error) {
+	constraint, err := ParseConstraint(version)
+	if err != nil {
+		return "", err
+	}
+	return HighestMatchingTag(tags, constraint, PrereleaseExclude)
+}
+
+// HighestVersionTag returns the release tag with the highest precedence, ignoring tags that
+// are not semver and pre-releases.
+func HighestVersionTag(tags []string) (string, error) {
+	for _, tag := range SortVersionTags(tags) {
+		if tagVersion, _ := ParseSemver(tag); !tagVersion.IsPrerelease() {
+			return tag, nil
+		}
+	}
+	return "", fmt.Errorf("no semver tags found")
+}
 
-		if strings.Contains(version, ".") {
-			requestedMajorMinor := fmt.Sprintf("%d.%d", tagVersion.Major, tagVersion.Minor)
-			if requestedMajorMinor == version {
-				semverVersion = tagVersion
-				break
-			}
-		} else if fmt.Sprintf("%d", tagVersion.Major) == version {
-			semverVersion = tagVersion
-			break
+// ExactTagForSHA returns the highest full major.minor.patch tag pointing at sha, such as the
+// release a floating v4 tag currently points to, and false when no such tag exists.
+func ExactTagForSHA(tags []Tag, sha string) (string, bool) {
+	var names []string
+	for _, tag := range tags {
+		if tag.SHA == sha && IsExactVersion(tag.Name) {
+			names = append(names, tag.Name)
 		}
 	}
-	return fmt.Sprintf("v%d.%d.%d", semverVersion.Major, semverVersion.Minor, semverVersion.Patch), nil
+	sorted := SortVersionTags(names)
+	if len(sorted) == 0 {
+		return "", false
+	}
+	return sorted[0], true
 }
 
 func FormatVersion(version string) string {
@@ -13,9 +13,20 @@ func TestParseSemver(t *testing.T) {
 	}{
 		{"v1.2.3", Semver{Major: 1, Minor: 2, Patch: 3}, nil},
 		{"v2.0.0", Semver{Major: 2, Minor: 0, Patch: 0}, nil},
-		{"v1.0.0-alpha", Semver{}, fmt.Errorf("invalid semver: v1.0.0-alpha")},
-		{"v1.2", Semver{}, fmt.Errorf("invalid semver: v1.2")},
+		{"v1.0.0-alpha", Semver{Major: 1, Minor: 0, Patch: 0, Prerelease: "alpha"}, nil},
+		{"v2.0.0-beta.1", Semver{Major: 2, Minor: 0, Patch: 0, Prerelease: "beta.1"}, nil},
+		{"v1.2.3+build5", Semver{Major: 1, Minor: 2, Patch: 3, Build: "build5"}, nil},
+		{"1.0.0-rc.1+exp.sha.5114f85", Semver{Major: 1, Minor: 0, Patch: 0, Prerelease: "rc.1", Build: "exp.sha.5114f85"}, nil},
+		{"v1.2", Semver{Major: 1, Minor: 2, Patch: 0}, nil},
+		{"1.2", Semver{Major: 1, Minor: 2, Patch: 0}, nil},
+		{"v1", Semver{}, fmt.Errorf("invalid semver: v1")},
 		{"v1.2.3.4", Semver{}, fmt.Errorf("invalid semver: v1.2.3.4")},
+		{"v01.2.3", Semver{}, fmt.Errorf("invalid semver: v01.2.3")},
+		{"v1.2.3-", Semver{}, fmt.Errorf("invalid semver: v1.2.3-")},
+		{"v1.2.3-beta..1", Semver{}, fmt.Errorf("invalid semver: v1.2.3-beta..1")},
+		{"v1.2.3-rc.01", Semver{}, fmt.Errorf("invalid semver: v1.2.3-rc.01")},
+		{"v1.2.3+", Semver{}, fmt.Errorf("invalid semver: v1.2.3+")},
+		{"main", Semver{}, fmt.Errorf("invalid semver: main")},
 	}
 
 	for _, test := range tests {
@@ -31,8 +42,51 @@ func TestParseSemver(t *testing.T) {
 	}
 }
 
+func TestSemverCompare(t *testing.T) {
+	// Ascending precedence, from the SemVer 2.0 specification plus numeric ordering cases.
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2",
+		"1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.9.0", "1.10.0", "2.0.0",
+	}
+	for i := 0; i < len(ordered); i++ {
+		for j := 0; j < len(ordered); j++ {
+			a, err := ParseSemver(ordered[i])
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %s: %v", ordered[i], err)
+			}
+			b, err := ParseSemver(ordered[j])
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %s: %v", ordered[j], err)
+			}
+			want := compareInt(i, j)
+			if got := a.Compare(b); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	a, _ := ParseSemver("1.2.3+build5")
+	b, _ := ParseSemver("1.2.3+build6")
+	if a.Compare(b) != 0 {
+		t.Errorf("Build metadata must not affect precedence")
+	}
+	if a.String() != "1.2.3+build5" {
+		t.Errorf("String() = %s, want 1.2.3+build5", a.String())
+	}
+}
+
+func TestSortVersionTags(t *testing.T) {
+	tags := []string{"v1.9.0", "main", "v1.10.0", "v1.10.0-rc.1", "v1.2", "v1.2.0", "v2"}
+	want := []string{"v1.10.0", "v1.10.0-rc.1", "v1.9.0", "v1.2.0", "v1.2"}
+	got := SortVersionTags(tags)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("SortVersionTags = %v, want %v", got, want)
+	}
+}
+
 func TestFindHighestPatchVersion(t *testing.T) {
-	tags := []string{"v1.2.3", "v2.0.0", "v1.0.0-alpha", "v1.2", "v1.3.1", "v3.5.0", "v3.4.0", "v3.5.2", "v3.5.1"}
+	tags := []string{"v1.2.3", "v2.0.0", "v1.0.0-alpha", "v1.2", "v1.3.1", "v3.5.0", "v3.4.0", "v3.5.2", "v3.5.1",
+		"v4.9.0", "v4.10.0", "v4.11.0-rc.1", "5.1.0"}
 
 	tests := []struct {
 		version  string
@@ -43,6 +97,8 @@ func TestFindHighestPatchVersion(t *testing.T) {
 		{"1.3", "v1.3.1"},
 		{"1", "v1.3.1"},
 		{"3", "v3.5.2"},
+		{"4", "v4.10.0"},
+		{"5", "5.1.0"},
 	}
 
 	for _, test := range tests {
@@ -54,3 +110,58 @@ func TestFindHighestPatchVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestFindHighestPatchVersionNoMatch(t *testing.T) {
+	tags := []string{"v1.2.3", "v2.0.0"}
+	if result, err := FindHighestPatchVersion(tags, "5"); err == nil {
+		t.Errorf("Expected error for unmatched version, got %s", result)
+	}
+	if result, err := FindHighestPatchVersion(nil, "1"); err == nil {
+		t.Errorf("Expected error for empty tag list, got %s", result)
+	}
+}
+
+func TestHighestVersionTag(t *testing.T) {
+	tags := []string{"v1.9.0", "v1.10.0", "main", "v1.2", "v1.0.0-alpha", "v1.11.0-beta.1"}
+	result, err := HighestVersionTag(tags)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "v1.10.0" {
+		t.Errorf("Unexpected result: got %s, want v1.10.0", result)
+	}
+	if _, err := HighestVersionTag([]string{"main"}); err == nil {
+		t.Errorf("Expected error when no tag is semver")
+	}
+}
+
+func TestExactTagForSHA(t *testing.T) {
+	const (
+		shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		shaC = "cccccccccccccccccccccccccccccccccccccccc"
+	)
+	tags := []Tag{
+		{Name: "v4", SHA: shaA},
+		{Name: "v4.1", SHA: shaA},
+		{Name: "v4.1.6", SHA: shaB},
+		{Name: "v4.1.7-rc.1", SHA: shaA},
+		{Name: "v4.1.7", SHA: shaA},
+		{Name: "v3", SHA: shaC},
+	}
+	tests := []struct {
+		sha    string
+		want   string
+		wantOk bool
+	}{
+		{sha: shaA, want: "v4.1.7", wantOk: true},
+		{sha: shaB, want: "v4.1.6", wantOk: true},
+		{sha: shaC, want: "", wantOk: false},
+	}
+	for _, test := range tests {
+		got, ok := ExactTagForSHA(tags, test.sha)
+		if got != test.want || ok != test.wantOk {
+			t.Errorf("ExactTagForSHA(%s) = (%q, %v), want (%q, %v)", test.sha, got, ok, test.want, test.wantOk)
+		}
+	}
+}
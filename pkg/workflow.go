@@ -0,0 +1,326 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UsesRef is a `uses:` value in a workflow or action file together with where its scalar sits in the
+// file, so that it can be rewritten without touching anything around it.
+type UsesRef struct {
+	// Value is the reference as YAML reads it, e.g. actions/checkout@v4.
+	Value string
+	// Line is the 1-based line the value is on.
+	Line int
+	// Image reports whether the value is the image of a job container or service, such as
+	// node:20, rather than a uses: reference.
+	Image bool
+	// Unlocated reports a value whose scalar couldn't be found in the file, such as a block
+	// scalar (uses: >-). It can be read but is never rewritten.
+	Unlocated bool
+	// start and end delimit the scalar in the file content, quotes included.
+	start, end int
+	// quote is the scalar's quote character, or 0 for a plain scalar.
+	quote byte
+}
+
+// UsesEdit rewrites the value of a UsesRef.
+type UsesEdit struct {
+	Ref UsesRef
+	// Value replaces the reference, in the same quoting style.
+	Value string
+	// Comment, when set, is written after the value as " #Comment", e.g. the tag a SHA was
+	// resolved from.
+	Comment string
+	// ReplaceComment replaces the version marker of an existing trailing comment, the first
+	// word after its "#" such as v4.1.1 in "# v4.1.1 keep", instead of inserting Comment in
+	// front of it. The rest of the comment is kept.
+	ReplaceComment bool
+}
+
+var (
+	// versionCommentRegexp matches the version marker of a trailing comment, e.g. " # v4.1.1",
+	// capturing the marker itself.
+	versionCommentRegexp = regexp.MustCompile(`^[ \t]+#[ \t]*(\S+)`)
+	// pinnedUsesRegexp matches a uses: value pinned to a full commit SHA.
+	pinnedUsesRegexp = regexp.MustCompile(`^([^\s@]+)@([0-9a-f]{40})$`)
+)
+
+// FindUsesRefs returns the `uses:` references of a workflow or composite action in file order:
+// reusable workflow calls at the job level (jobs.<id>.uses), the actions of job steps
+// (jobs.<id>.steps[].uses) and the actions of a composite action's steps (runs.steps[].uses).
+// Aliases are skipped: an anchored value is found, and rewritten, where it is defined. Values
+// that can't be located in the file, such as block scalars, are returned Unlocated.
+func FindUsesRefs(content []byte) ([]UsesRef, error) {
+	root, err := parseRoot(content)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*yaml.Node
+	for _, job := range mappingValues(mappingValue(root, "jobs")) {
+		nodes = append(nodes, mappingValue(job, "uses"))
+		for _, step := range sequenceItems(mappingValue(job, "steps")) {
+			nodes = append(nodes, mappingValue(step, "uses"))
+		}
+	}
+	for _, step := range sequenceItems(mappingValue(mappingValue(root, "runs"), "steps")) {
+		nodes = append(nodes, mappingValue(step, "uses"))
+	}
+	return locateScalars(content, nodes), nil
+}
+
+// FindPinnedRefs returns the SHA-pinned references among the `uses:` values FindUsesRefs finds,
+// in file order, each with the version marker of the comment that follows its value on the
+// same line. Text that only looks like a uses: line, such as in a run: script, is ignored.
+func FindPinnedRefs(content []byte) ([]PinnedRef, error) {
+	refs, err := FindUsesRefs(content)
+	if err != nil {
+		return nil, err
+	}
+	var pinned []PinnedRef
+	for _, ref := range refs {
+		m := pinnedUsesRegexp.FindStringSubmatch(ref.Value)
+		if m == nil {
+			continue
+		}
+		pinned = append(pinned, PinnedRef{Line: ref.Line, Action: ref.Value, Repository: m[1], SHA: m[2], Comment: versionComment(content, ref)})
+	}
+	return pinned, nil
+}
+
+// versionComment returns the version marker of the comment following ref on its line, or ""
+// when there is none or ref couldn't be located.
+func versionComment(content []byte, ref UsesRef) string {
+	if ref.Unlocated {
+		return ""
+	}
+	rest := content[ref.end:]
+	if lineEnd := bytes.IndexByte(rest, '\n'); lineEnd >= 0 {
+		rest = rest[:lineEnd]
+	}
+	if m := versionCommentRegexp.FindSubmatch(rest); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// FindImageRefs returns the container images of a workflow's jobs in file order: the job
+// container (jobs.<id>.container or jobs.<id>.container.image) followed by the images of its
+// services (jobs.<id>.services.<name>.image). Images set from an expression are skipped.
+func FindImageRefs(content []byte) ([]UsesRef, error) {
+	root, err := parseRoot(content)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*yaml.Node
+	for _, job := range mappingValues(mappingValue(root, "jobs")) {
+		container := mappingValue(job, "container")
+		if container != nil && container.Kind == yaml.MappingNode {
+			container = mappingValue(container, "image")
+		}
+		nodes = append(nodes, container)
+		for _, service := range mappingValues(mappingValue(job, "services")) {
+			nodes = append(nodes, mappingValue(service, "image"))
+		}
+	}
+	var images []UsesRef
+	for _, ref := range locateScalars(content, nodes) {
+		if ref.Value == "" || strings.Contains(ref.Value, "${{") {
+			continue
+		}
+		ref.Image = true
+		images = append(images, ref)
+	}
+	return images, nil
+}
+
+// parseRoot parses a YAML file and returns its top-level node, or nil when it is empty.
+func parseRoot(content []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// locateScalars locates the scalar nodes among nodes, skipping nil and non-scalar ones. A
+// scalar that can't be located is returned as Unlocated rather than failing the others.
+func locateScalars(content []byte, nodes []*yaml.Node) []UsesRef {
+	var refs []UsesRef
+	for _, node := range nodes {
+		if node == nil || node.Kind != yaml.ScalarNode {
+			continue
+		}
+		ref, err := locateScalar(content, node)
+		if err != nil {
+			ref = UsesRef{Value: node.Value, Line: node.Line, Unlocated: true}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// RewriteUses applies edits to content. Only the edited scalars and the comments written
+// after them change; comments, quoting, indentation, anchors and line endings elsewhere are
+// kept byte for byte. No comment is written where one would swallow the rest of the line, as
+// in a flow mapping such as `{ uses: actions/checkout@v4 }`. Edits of Unlocated refs are
+// skipped.
+func RewriteUses(content []byte, edits []UsesEdit) []byte {
+	sorted := append([]UsesEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Ref.start < sorted[j].Ref.start })
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range sorted {
+		ref := edit.Ref
+		if ref.Unlocated || ref.start < last || ref.end > len(content) {
+			continue
+		}
+		out.Write(content[last:ref.start])
+		out.WriteString(quoteScalar(edit.Value, ref.quote))
+		last = ref.end
+
+		lineEnd := bytes.IndexByte(content[ref.end:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content) - ref.end
+		}
+		rest := content[ref.end : ref.end+lineEnd]
+		trimmed := strings.TrimSpace(string(rest))
+		if edit.Comment == "" || (trimmed != "" && !strings.HasPrefix(trimmed, "#")) {
+			continue
+		}
+		out.WriteString(" #" + edit.Comment)
+		if edit.ReplaceComment {
+			last += len(versionCommentRegexp.Find(rest))
+		}
+	}
+	out.Write(content[last:])
+	return out.Bytes()
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingValues returns the values of a mapping node in file order.
+func mappingValues(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var values []*yaml.Node
+	for i := 1; i < len(node.Content); i += 2 {
+		values = append(values, node.Content[i])
+	}
+	return values
+}
+
+// sequenceItems returns the items of a sequence node.
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
+
+// locateScalar finds the bytes of a plain or quoted scalar node in content. yaml.v3 reports
+// 1-based line and character columns pointing at the node's anchor or tag when it has one.
+func locateScalar(content []byte, node *yaml.Node) (UsesRef, error) {
+	ref := UsesRef{Value: node.Value, Line: node.Line}
+	fail := fmt.Errorf("line %d: cannot locate value %q", node.Line, node.Value)
+
+	offset, line := 0, 1
+	for line < node.Line {
+		next := bytes.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			return UsesRef{}, fail
+		}
+		offset += next + 1
+		line++
+	}
+	if line == 1 {
+		offset = len(content) - len(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")))
+	}
+	for column := 1; column < node.Column; column++ {
+		if offset >= len(content) || content[offset] == '\n' {
+			return UsesRef{}, fail
+		}
+		_, size := utf8.DecodeRune(content[offset:])
+		offset += size
+	}
+	// Skip node properties: an &anchor and/or a !tag, each followed by blanks.
+	for offset < len(content) && (content[offset] == '&' || content[offset] == '!') {
+		for offset < len(content) && !isBlank(content[offset]) {
+			offset++
+		}
+		for offset < len(content) && isBlank(content[offset]) {
+			offset++
+		}
+	}
+	ref.start = offset
+
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		ref.quote = '"'
+		for i := offset + 1; i < len(content); i++ {
+			switch content[i] {
+			case '\\':
+				i++
+			case '"':
+				ref.end = i + 1
+				return ref, nil
+			}
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		ref.quote = '\''
+		for i := offset + 1; i < len(content); i++ {
+			if content[i] != '\'' {
+				continue
+			}
+			if i+1 < len(content) && content[i+1] == '\'' {
+				i++
+				continue
+			}
+			ref.end = i + 1
+			return ref, nil
+		}
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0:
+		if bytes.HasPrefix(content[offset:], []byte(node.Value)) {
+			ref.end = offset + len(node.Value)
+			return ref, nil
+		}
+	}
+	return UsesRef{}, fail
+}
+
+func isBlank(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// quoteScalar writes value in the given quoting style.
+func quoteScalar(value string, quote byte) string {
+	switch quote {
+	case '"':
+		return strconv.Quote(value)
+	case '\'':
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return value
+}
@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFindUsesRefs(t *testing.T) {
+	content := "name: actions/checkout@v4 ✓\n" +
+		"# uses: actions/checkout@v4\n" +
+		"jobs:\n" +
+		"  build:\n" +
+		"    steps:\n" +
+		"      - name: run actions/checkout@v4\n" +
+		"        run: echo actions/checkout@v4\n" +
+		"      - name: é\n" +
+		"        uses: actions/checkout@v4 # first\n" +
+		"      - uses: &setup 'actions/setup-go@v5'\n" +
+		"      - uses: *setup\n" +
+		"      - { name: cache, uses: \"actions/cache@v3\" }\n" +
+		"  test:\n" +
+		"    steps:\n" +
+		"      - uses: !!str ./.github/actions/local\n" +
+		"  release:\n" +
+		"    needs: [build]\n" +
+		"    uses: octo-org/shared/.github/workflows/release.yml@main\n" +
+		"    with:\n" +
+		"      uses: not-an-action@v1\n"
+	refs, err := FindUsesRefs([]byte(content))
+	if err != nil {
+		t.Fatalf("FindUsesRefs unexpected error: %v", err)
+	}
+	want := []struct {
+		value string
+		line  int
+		raw   string
+	}{
+		{"actions/checkout@v4", 9, "actions/checkout@v4"},
+		{"actions/setup-go@v5", 10, "'actions/setup-go@v5'"},
+		{"actions/cache@v3", 12, `"actions/cache@v3"`},
+		{"./.github/actions/local", 15, "./.github/actions/local"},
+		{"octo-org/shared/.github/workflows/release.yml@main", 18, "octo-org/shared/.github/workflows/release.yml@main"},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("FindUsesRefs returned %d refs, want %d: %+v", len(refs), len(want), refs)
+	}
+	for i, w := range want {
+		ref := refs[i]
+		if ref.Value != w.value || ref.Line != w.line || content[ref.start:ref.end] != w.raw {
+			t.Errorf("ref %d = %q on line %d at %q, want %q on line %d at %q",
+				i, ref.Value, ref.Line, content[ref.start:ref.end], w.value, w.line, w.raw)
+		}
+	}
+}
+
+func TestFindUsesRefsCompositeAction(t *testing.T) {
+	content := "name: setup\n" +
+		"description: uses actions/checkout@v4\n" +
+		"runs:\n" +
+		"  using: composite\n" +
+		"  steps:\n" +
+		"    - uses: actions/setup-node@v4\n" +
+		"      with:\n" +
+		"        node-version: 20\n" +
+		"    - run: echo \"uses actions/cache@v3\"\n" +
+		"      shell: bash\n" +
+		"    - uses: ./.github/actions/other\n"
+	refs, err := FindUsesRefs([]byte(content))
+	if err != nil {
+		t.Fatalf("FindUsesRefs unexpected error: %v", err)
+	}
+	var got []string
+	for _, ref := range refs {
+		got = append(got, fmt.Sprintf("%d:%s", ref.Line, ref.Value))
+	}
+	if want := "[6:actions/setup-node@v4 11:./.github/actions/other]"; fmt.Sprint(got) != want {
+		t.Errorf("FindUsesRefs = %v, want %s", got, want)
+	}
+}
+
+func TestFindUsesRefsInvalid(t *testing.T) {
+	if _, err := FindUsesRefs([]byte("jobs: [unclosed\n")); err == nil {
+		t.Errorf("Expected error for invalid YAML")
+	}
+	refs, err := FindUsesRefs(nil)
+	if err != nil || len(refs) != 0 {
+		t.Errorf("FindUsesRefs(nil) = %v, %v, want no refs", refs, err)
+	}
+}
+
+func TestFindUsesRefsBlockScalar(t *testing.T) {
+	content := "jobs:\n" +
+		"  build:\n" +
+		"    steps:\n" +
+		"      - uses: actions/checkout@v4\n" +
+		"      - uses: >-\n" +
+		"          actions/setup-go@v5\n" +
+		"      - uses: actions/cache@v3\n"
+	refs, err := FindUsesRefs([]byte(content))
+	if err != nil {
+		t.Fatalf("FindUsesRefs unexpected error: %v", err)
+	}
+	var got []string
+	for _, ref := range refs {
+		got = append(got, fmt.Sprintf("%d:%s:%v", ref.Line, ref.Value, ref.Unlocated))
+	}
+	if want := "[4:actions/checkout@v4:false 5:actions/setup-go@v5:true 7:actions/cache@v3:false]"; fmt.Sprint(got) != want {
+		t.Fatalf("FindUsesRefs = %v, want %s", got, want)
+	}
+
+	var edits []UsesEdit
+	for _, ref := range refs {
+		edits = append(edits, UsesEdit{Ref: ref, Value: strings.Replace(ref.Value, "@v", "@pinned-v", 1)})
+	}
+	want := strings.NewReplacer("checkout@v4", "checkout@pinned-v4", "cache@v3", "cache@pinned-v3").Replace(content)
+	if got := string(RewriteUses([]byte(content), edits)); got != want {
+		t.Errorf("RewriteUses = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteUses(t *testing.T) {
+	const (
+		shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	tests := []struct {
+		name    string
+		content string
+		edit    func(ref UsesRef) UsesEdit
+		want    string
+	}{
+		{
+			name: "only the uses scalar changes",
+			content: "# pins actions/checkout@v4\n" +
+				"jobs:\n" +
+				"  build:\n" +
+				"    steps:\n" +
+				"      - name: actions/checkout@v4\n" +
+				"        run: |\n" +
+				"          echo actions/checkout@v4\n" +
+				"      - uses: actions/checkout@v4\n",
+			edit: func(ref UsesRef) UsesEdit {
+				return UsesEdit{Ref: ref, Value: "actions/checkout@" + shaA, Comment: "v4.2.2"}
+			},
+			want: "# pins actions/checkout@v4\n" +
+				"jobs:\n" +
+				"  build:\n" +
+				"    steps:\n" +
+				"      - name: actions/checkout@v4\n" +
+				"        run: |\n" +
+				"          echo actions/checkout@v4\n" +
+				"      - uses: actions/checkout@" + shaA + " #v4.2.2\n",
+		},
+		{
+			name:    "quoting and CRLF are kept",
+			content: "jobs:\r\n  build:\r\n    steps:\r\n      - uses: \"actions/checkout@v4\"\r\n      - uses: 'actions/checkout@v4'   # keep\r\n",
+			edit: func(ref UsesRef) UsesEdit {
+				return UsesEdit{Ref: ref, Value: "actions/checkout@" + shaA, Comment: "v4.2.2"}
+			},
+			want: "jobs:\r\n  build:\r\n    steps:\r\n" +
+				"      - uses: \"actions/checkout@" + shaA + "\" #v4.2.2\r\n" +
+				"      - uses: 'actions/checkout@" + shaA + "' #v4.2.2   # keep\r\n",
+		},
+		{
+			name:    "anchor is kept",
+			content: "jobs:\n  build:\n    steps:\n      - uses: &checkout actions/checkout@v4\n      - uses: *checkout\n",
+			edit: func(ref UsesRef) UsesEdit {
+				return UsesEdit{Ref: ref, Value: "actions/checkout@" + shaA, Comment: "v4.2.2"}
+			},
+			want: "jobs:\n  build:\n    steps:\n      - uses: &checkout actions/checkout@" + shaA + " #v4.2.2\n      - uses: *checkout\n",
+		},
+		{
+			name:    "stale version marker is replaced",
+			content: "jobs:\n  build:\n    steps:\n      - uses: actions/checkout@" + shaA + " # v4.1.1 keep\n",
+			edit: func(ref UsesRef) UsesEdit {
+				return UsesEdit{Ref: ref, Value: "actions/checkout@" + shaB, Comment: "v4.2.2", ReplaceComment: true}
+			},
+			want: "jobs:\n  build:\n    steps:\n      - uses: actions/checkout@" + shaB + " #v4.2.2 keep\n",
+		},
+		{
+			name:    "no comment inside a flow mapping",
+			content: "jobs:\n  build:\n    steps:\n      - { uses: actions/checkout@v4 }\n",
+			edit: func(ref UsesRef) UsesEdit {
+				return UsesEdit{Ref: ref, Value: "actions/checkout@" + shaA, Comment: "v4.2.2"}
+			},
+			want: "jobs:\n  build:\n    steps:\n      - { uses: actions/checkout@" + shaA + " }\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs, err := FindUsesRefs([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("FindUsesRefs unexpected error: %v", err)
+			}
+			var edits []UsesEdit
+			for _, ref := range refs {
+				if strings.HasPrefix(ref.Value, "actions/") {
+					edits = append(edits, tt.edit(ref))
+				}
+			}
+			if got := string(RewriteUses([]byte(tt.content), edits)); got != tt.want {
+				t.Errorf("RewriteUses = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindImageRefs(t *testing.T) {
+	content := "jobs:\n" +
+		"  build:\n" +
+		"    container: node:20\n" +
+		"    services:\n" +
+		"      redis:\n" +
+		"        image: 'redis:7'\n" +
+		"      db:\n" +
+		"        image: ${{ matrix.db }}\n" +
+		"    steps:\n" +
+		"      - uses: docker://alpine:3.19\n" +
+		"  test:\n" +
+		"    container:\n" +
+		"      image: ghcr.io/org/tool:1.4 # tool\n" +
+		"      options: --cpus 1\n"
+	refs, err := FindImageRefs([]byte(content))
+	if err != nil {
+		t.Fatalf("FindImageRefs unexpected error: %v", err)
+	}
+	want := []struct {
+		value string
+		line  int
+		raw   string
+	}{
+		{"node:20", 3, "node:20"},
+		{"redis:7", 6, "'redis:7'"},
+		{"ghcr.io/org/tool:1.4", 13, "ghcr.io/org/tool:1.4"},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("FindImageRefs returned %d refs, want %d: %+v", len(refs), len(want), refs)
+	}
+	for i, w := range want {
+		ref := refs[i]
+		if !ref.Image || ref.Value != w.value || ref.Line != w.line || content[ref.start:ref.end] != w.raw {
+			t.Errorf("image %d = %+v at %q, want %q on line %d at %q", i, ref, content[ref.start:ref.end], w.value, w.line, w.raw)
+		}
+	}
+}
+
+func TestFindPinnedRefs(t *testing.T) {
+	const (
+		shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	content := "jobs:\n" +
+		"  build:\n" +
+		"    steps:\n" +
+		"      - uses: actions/checkout@" + shaA + " # v4.1.1\r\n" +
+		"      - uses: actions/checkout@v4\n" +
+		"      # - uses: actions/cache@" + shaB + " # v3.0.0\n" +
+		"      - name: Build\n" +
+		"        uses: 'docker/build-push-action/sub@" + shaB + "' #v5.0.0 keep\n" +
+		"      - uses: actions/setup-go@" + shaB + "\n" +
+		"      - run: |\n" +
+		"          echo 'uses: actions/cache@" + shaB + " # v9.9.9'\n" +
+		"        env:\n" +
+		"          NOTE: \"uses: actions/cache@" + shaB + " # v9.9.9\"\n"
+	want := []PinnedRef{
+		{Line: 4, Action: "actions/checkout@" + shaA, Repository: "actions/checkout", SHA: shaA, Comment: "v4.1.1"},
+		{Line: 8, Action: "docker/build-push-action/sub@" + shaB, Repository: "docker/build-push-action/sub", SHA: shaB, Comment: "v5.0.0"},
+		{Line: 9, Action: "actions/setup-go@" + shaB, Repository: "actions/setup-go", SHA: shaB},
+	}
+	got, err := FindPinnedRefs([]byte(content))
+	if err != nil {
+		t.Fatalf("FindPinnedRefs unexpected error: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("FindPinnedRefs returned %d refs, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("FindPinnedRefs()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}